@@ -0,0 +1,141 @@
+package grimoire
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	ErrAuthorLength = errors.New("author must have length of at most 64")
+	ErrNoteLength   = errors.New("note must have length of at most 1000")
+)
+
+const (
+	maxAuthorLength = 64
+	maxNoteLength   = 1000
+)
+
+type AddPlayer struct {
+	Action      string    `json:"action"`
+	Id          int       `json:"id"`
+	AfterPlayer int       `json:"afterPlayer,omitempty"`
+	Character   string    `json:"character,omitempty"`
+	Alignment   Alignment `json:"alignment,omitempty"`
+}
+
+type RemovePlayer struct {
+	Action string `json:"action"`
+	Id     int    `json:"id"`
+}
+
+type MovePlayer struct {
+	Action      string `json:"action"`
+	Id          int    `json:"id"`
+	AfterPlayer int    `json:"afterPlayer"`
+}
+
+type UpdatePlayer struct {
+	Action    string    `json:"action"`
+	Id        int       `json:"id"`
+	Character string    `json:"character,omitempty"`
+	Alignment Alignment `json:"alignment,omitempty"`
+}
+
+type AddReminder struct {
+	Action     string           `json:"action"`
+	Character  string           `json:"character"`
+	Token      string           `json:"token"`
+	Position   ReminderPosition `json:"position"`
+	Until      string           `json:"until,omitempty"`
+	UntilPhase int              `json:"untilPhase,omitempty"`
+}
+
+type RemoveReminder struct {
+	Action    string           `json:"action"`
+	Character string           `json:"character"`
+	Token     string           `json:"token"`
+	Position  ReminderPosition `json:"position"`
+}
+
+type MoveReminder struct {
+	Action       string           `json:"action"`
+	Character    string           `json:"character"`
+	Token        string           `json:"token"`
+	FromPosition ReminderPosition `json:"fromPosition"`
+	ToPosition   ReminderPosition `json:"toPosition"`
+}
+
+type AdvancePhase struct {
+	Action string `json:"action"`
+	Phase  int    `json:"phase,omitempty"`
+}
+
+// ActionMeta describes who applied an action and why. It is sent along with
+// an action as its meta member.
+type ActionMeta struct {
+	Author     string     `json:"author,omitempty"`
+	ClientTime *time.Time `json:"clientTime,omitempty"`
+	Note       string     `json:"note,omitempty"`
+}
+
+type WrappedAction struct {
+	Action any
+	Meta   ActionMeta
+}
+
+func (wa *WrappedAction) UnmarshalJSON(data []byte) error {
+	var actionType struct {
+		Action string     `json:"action"`
+		Meta   ActionMeta `json:"meta"`
+	}
+	err := json.Unmarshal(data, &actionType)
+	if err != nil {
+		return err
+	}
+	if utf8.RuneCountInString(actionType.Meta.Author) > maxAuthorLength {
+		return ErrAuthorLength
+	}
+	if utf8.RuneCountInString(actionType.Meta.Note) > maxNoteLength {
+		return ErrNoteLength
+	}
+	wa.Meta = actionType.Meta
+	wa.Action, err = decodeAction(actionType.Action, data)
+	return err
+}
+
+func init() {
+	RegisterAction(ActionType[AddPlayer]{Name: "addPlayer", Apply: Game.AddPlayer})
+	RegisterAction(ActionType[RemovePlayer]{Name: "removePlayer", Apply: Game.RemovePlayer})
+	RegisterAction(ActionType[MovePlayer]{Name: "movePlayer", Apply: Game.MovePlayer})
+	RegisterAction(ActionType[UpdatePlayer]{Name: "updatePlayer", Apply: Game.UpdatePlayer})
+	RegisterAction(ActionType[AddReminder]{
+		Name:  "addReminder",
+		Apply: Game.AddReminder,
+		Inverse: func(game Game, addReminder AddReminder) (any, error) {
+			cPos, err := game.CanonicalReminderPosition(addReminder.Position)
+			if err != nil {
+				return nil, err
+			}
+			return RemoveReminder{Action: "removeReminder", Character: addReminder.Character, Token: addReminder.Token, Position: cPos}, nil
+		},
+	})
+	RegisterAction(ActionType[RemoveReminder]{Name: "removeReminder", Apply: Game.RemoveReminder})
+	RegisterAction(ActionType[MoveReminder]{
+		Name:  "moveReminder",
+		Apply: Game.MoveReminder,
+		Inverse: func(game Game, moveReminder MoveReminder) (any, error) {
+			fromPosition, err := game.CanonicalReminderPosition(moveReminder.FromPosition)
+			if err != nil {
+				return nil, err
+			}
+			toPosition, err := game.CanonicalReminderPosition(moveReminder.ToPosition)
+			if err != nil {
+				return nil, err
+			}
+			return MoveReminder{Action: "moveReminder", Character: moveReminder.Character, Token: moveReminder.Token, FromPosition: toPosition, ToPosition: fromPosition}, nil
+		},
+	})
+	RegisterAction(ActionType[AdvancePhase]{Name: "advancePhase", Apply: Game.AdvancePhase})
+}
@@ -0,0 +1,60 @@
+package grimoire_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/phedny/powergrim-server/grimoire"
+)
+
+func TestMarshalAction(t *testing.T) {
+	data, err := json.Marshal(grimoire.AddPlayer{
+		Action: "addPlayer",
+		Id:     1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"action":"addPlayer","id":1}`
+	if string(data) != expected {
+		t.Fatalf("json.Marshal() returned %q; expected %q", data, expected)
+	}
+}
+
+func TestUnmarshalAction(t *testing.T) {
+	data := `{"action":"addPlayer","id":1}`
+	var wa grimoire.WrappedAction
+	err := json.Unmarshal([]byte(data), &wa)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := grimoire.AddPlayer{
+		Action: "addPlayer",
+		Id:     1,
+	}
+	if wa.Action != expected {
+		t.Fatalf("json.Unmarshal() wrote %#v; expected %#v", wa.Action, expected)
+	}
+}
+
+func TestUnmarshalActionMeta(t *testing.T) {
+	data := `{"action":"removePlayer","id":4,"meta":{"author":"st2","clientTime":"2024-05-01T20:15:00Z","note":"Poisoner picked seat 4"}}`
+	var wa grimoire.WrappedAction
+	if err := json.Unmarshal([]byte(data), &wa); err != nil {
+		t.Fatal(err)
+	}
+	clientTime := time.Date(2024, 5, 1, 20, 15, 0, 0, time.UTC)
+	if wa.Meta.Author != "st2" || wa.Meta.Note != "Poisoner picked seat 4" || wa.Meta.ClientTime == nil || !wa.Meta.ClientTime.Equal(clientTime) {
+		t.Fatalf("json.Unmarshal() wrote meta %#v", wa.Meta)
+	}
+	if expected := (grimoire.RemovePlayer{Action: "removePlayer", Id: 4}); wa.Action != expected {
+		t.Fatalf("json.Unmarshal() wrote %#v; expected %#v", wa.Action, expected)
+	}
+
+	data = `{"action":"removePlayer","id":4,"meta":{"note":"` + strings.Repeat("x", 1001) + `"}}`
+	if err := json.Unmarshal([]byte(data), &wa); err != grimoire.ErrNoteLength {
+		t.Fatalf("json.Unmarshal() returned %v; expected %v", err, grimoire.ErrNoteLength)
+	}
+}
@@ -1,4 +1,8 @@
-package main
+// Package grimoire implements the state of a Blood on the Clocktower game and
+// the actions that change it. Other packages can add actions by calling
+// RegisterAction from an init function; a server binary that imports such a
+// package next to the server package makes them available in PATCH requests.
+package grimoire
 
 import (
 	"encoding/json"
@@ -72,43 +76,13 @@ type Reminder struct {
 	Character string           `json:"character"`
 	Token     string           `json:"token"`
 	Position  ReminderPosition `json:"position"`
+	ExpiresAt int              `json:"expiresAt,omitempty"`
 }
 
 type Game struct {
 	Script    string     `json:"script"`
+	Layout    string     `json:"layout,omitempty"`
+	Phase     int        `json:"phase,omitempty"`
 	Players   []Player   `json:"players"`
 	Reminders []Reminder `json:"reminders"`
 }
-
-type Script struct {
-	Id            string           `json:"id"`
-	Name          string           `json:"name"`
-	Complexity    ScriptComplexity `json:"complexity,omitempty"`
-	Tagline       string           `json:"tagline"`
-	Url           string           `json:"url,omitempty"`
-	Logo          string           `json:"logo,omitempty"`
-	Description   string           `json:"description"`
-	KeyCharacters []string         `json:"keyCharacters,omitempty"`
-	Characters    []string         `json:"characters"`
-}
-
-type ScriptComplexity struct {
-	Level       string  `json:"level,omitempty"`
-	Storyteller float64 `json:"storyteller,omitempty"`
-	Player      float64 `json:"player,omitempty"`
-}
-
-type ScriptFile struct {
-	Name    string   `json:"name"`
-	Author  string   `json:"author,omitempty"`
-	Url     string   `json:"url,omitempty"`
-	Scripts []Script `json:"scripts"`
-}
-
-type Layout struct {
-	Name           string `json:"name"`
-	Dimensions     [2]int `json:"dimensions"`
-	BackgroundUrl  string `json:"backgroundUrl"`
-	SeatingPath    string `json:"seatingPath,omitempty"`
-	NewPlayerToken [2]int `json:"newPlayerToken"`
-}
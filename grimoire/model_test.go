@@ -1,17 +1,17 @@
-package main_test
+package grimoire_test
 
 import (
 	"encoding/json"
 	"testing"
 
-	powergrim "github.com/phedny/powergrim-server"
+	"github.com/phedny/powergrim-server/grimoire"
 )
 
 func TestMarshalCentralReminder(t *testing.T) {
-	reminder := powergrim.Reminder{
+	reminder := grimoire.Reminder{
 		Character: "Drunk",
 		Token:     "Is the Drunk",
-		Position:  powergrim.ReminderPosition{},
+		Position:  grimoire.ReminderPosition{},
 	}
 
 	got, err := json.Marshal(reminder)
@@ -26,16 +26,16 @@ func TestMarshalCentralReminder(t *testing.T) {
 }
 
 func TestUnmarshalCentralReminder(t *testing.T) {
-	var got powergrim.Reminder
+	var got grimoire.Reminder
 	err := json.Unmarshal([]byte(`{"character":"Drunk","token":"Is the Drunk","position":"central"}`), &got)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	expected := powergrim.Reminder{
+	expected := grimoire.Reminder{
 		Character: "Drunk",
 		Token:     "Is the Drunk",
-		Position:  powergrim.ReminderPosition{},
+		Position:  grimoire.ReminderPosition{},
 	}
 	if got != expected {
 		t.Fatalf("json.Unmarshal() returned %#v; expected %#v", got, expected)
@@ -43,10 +43,10 @@ func TestUnmarshalCentralReminder(t *testing.T) {
 }
 
 func TestMarshalPlayerReminder(t *testing.T) {
-	reminder := powergrim.Reminder{
+	reminder := grimoire.Reminder{
 		Character: "Drunk",
 		Token:     "Is the Drunk",
-		Position:  powergrim.ReminderPosition{2},
+		Position:  grimoire.ReminderPosition{2},
 	}
 
 	got, err := json.Marshal(reminder)
@@ -61,16 +61,16 @@ func TestMarshalPlayerReminder(t *testing.T) {
 }
 
 func TestUnmarshalPlayerReminder(t *testing.T) {
-	var got powergrim.Reminder
+	var got grimoire.Reminder
 	err := json.Unmarshal([]byte(`{"character":"Drunk","token":"Is the Drunk","position":2}`), &got)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	expected := powergrim.Reminder{
+	expected := grimoire.Reminder{
 		Character: "Drunk",
 		Token:     "Is the Drunk",
-		Position:  powergrim.ReminderPosition{2},
+		Position:  grimoire.ReminderPosition{2},
 	}
 	if got != expected {
 		t.Fatalf("json.Unmarshal() returned %#v; expected %#v", got, expected)
@@ -78,10 +78,10 @@ func TestUnmarshalPlayerReminder(t *testing.T) {
 }
 
 func TestMarshalSharedReminder(t *testing.T) {
-	reminder := powergrim.Reminder{
+	reminder := grimoire.Reminder{
 		Character: "Revolutionary",
 		Token:     "Register falsely?",
-		Position:  powergrim.ReminderPosition{2, 3},
+		Position:  grimoire.ReminderPosition{2, 3},
 	}
 
 	got, err := json.Marshal(reminder)
@@ -96,16 +96,16 @@ func TestMarshalSharedReminder(t *testing.T) {
 }
 
 func TestUnmarshalSharedReminder(t *testing.T) {
-	var got powergrim.Reminder
+	var got grimoire.Reminder
 	err := json.Unmarshal([]byte(`{"character":"Revolutionary","token":"Register falsely?","position":[2,3]}`), &got)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	expected := powergrim.Reminder{
+	expected := grimoire.Reminder{
 		Character: "Revolutionary",
 		Token:     "Register falsely?",
-		Position:  powergrim.ReminderPosition{2, 3},
+		Position:  grimoire.ReminderPosition{2, 3},
 	}
 	if got != expected {
 		t.Fatalf("json.Unmarshal() returned %#v; expected %#v", got, expected)
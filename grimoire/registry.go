@@ -0,0 +1,146 @@
+package grimoire
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+var ErrNoInverse = errors.New("action has no inverse")
+
+// ActionType describes an action that can be applied to a game. Apply is
+// required. Decode decodes an action from the JSON object it is sent as and
+// defaults to json.Unmarshal, Validate checks an action independently of the
+// game when it is decoded, and Inverse returns, given the game before action
+// is applied, the action that undoes it. Errors lists the errors Validate and
+// Apply can return, so that they are reported with a stable problem code.
+type ActionType[A any] struct {
+	Name     string
+	Decode   func(data []byte) (A, error)
+	Validate func(action A) error
+	Apply    func(game Game, action A) (Game, error)
+	Inverse  func(game Game, action A) (any, error)
+	Errors   []ActionError
+}
+
+// ActionError describes an error that actions fail with. Code is the problem
+// code reported to clients, Name is reported in metrics and Field names the
+// member of the action that caused the error, if any.
+type ActionError struct {
+	Err   error
+	Name  string
+	Code  string
+	Field string
+}
+
+type registeredAction struct {
+	name    string
+	decode  func(data []byte) (any, error)
+	apply   func(game Game, action any) (Game, error)
+	inverse func(game Game, action any) (any, error)
+}
+
+var (
+	actionsByName = make(map[string]registeredAction)
+	actionsByType = make(map[reflect.Type]registeredAction)
+	actionErrors  []ActionError
+)
+
+// RegisterAction makes an action type available in PATCH requests. It must
+// be called before the server starts, typically from an init function in the
+// package that defines the action, and panics if the name or the Go type of
+// the action is already registered.
+func RegisterAction[A any](actionType ActionType[A]) {
+	typ := reflect.TypeFor[A]()
+	if actionType.Name == "" || actionType.Apply == nil {
+		panic(fmt.Sprintf("action type %s must have a name and an apply function", typ))
+	}
+	if _, ok := actionsByName[actionType.Name]; ok {
+		panic(fmt.Sprintf("action %q is already registered", actionType.Name))
+	}
+	if _, ok := actionsByType[typ]; ok {
+		panic(fmt.Sprintf("action type %s is already registered", typ))
+	}
+	for _, actionError := range actionType.Errors {
+		if actionError.Err == nil || actionError.Name == "" || actionError.Code == "" {
+			panic(fmt.Sprintf("errors of action %q must have an error, a name and a code", actionType.Name))
+		}
+	}
+	decode := actionType.Decode
+	if decode == nil {
+		decode = func(data []byte) (A, error) {
+			var action A
+			err := json.Unmarshal(data, &action)
+			return action, err
+		}
+	}
+	action := registeredAction{
+		name: actionType.Name,
+		decode: func(data []byte) (any, error) {
+			action, err := decode(data)
+			if err != nil {
+				return action, err
+			}
+			if actionType.Validate != nil {
+				if err := actionType.Validate(action); err != nil {
+					return action, err
+				}
+			}
+			return action, nil
+		},
+		apply: func(game Game, action any) (Game, error) {
+			return actionType.Apply(game, action.(A))
+		},
+	}
+	if actionType.Inverse != nil {
+		action.inverse = func(game Game, action any) (any, error) {
+			return actionType.Inverse(game, action.(A))
+		}
+	}
+	actionsByName[actionType.Name] = action
+	actionsByType[typ] = action
+	actionErrors = append(actionErrors, actionType.Errors...)
+}
+
+func decodeAction(name string, data []byte) (any, error) {
+	action, ok := actionsByName[name]
+	if !ok {
+		return nil, ErrInvalidAction
+	}
+	return action.decode(data)
+}
+
+// ActionName returns the name an action is registered with, or "unknown".
+func ActionName(action any) string {
+	if action, ok := actionsByType[reflect.TypeOf(action)]; ok {
+		return action.name
+	}
+	return "unknown"
+}
+
+// ActionErrors returns the errors of all registered action types, in the
+// order they were registered in.
+func ActionErrors() []ActionError {
+	return actionErrors
+}
+
+func (game Game) ApplyAction(action any) (Game, error) {
+	registered, ok := actionsByType[reflect.TypeOf(action)]
+	if !ok {
+		return Game{}, ErrInvalidAction
+	}
+	return registered.apply(game, action)
+}
+
+// InverseAction returns the action that undoes applying action to game.
+func (game Game) InverseAction(action any) (any, error) {
+	registered, ok := actionsByType[reflect.TypeOf(action)]
+	if !ok {
+		return nil, ErrInvalidAction
+	}
+	if registered.inverse == nil {
+		return nil, ErrNoInverse
+	}
+	return registered.inverse(game, action)
+}
@@ -0,0 +1,122 @@
+package grimoire_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/phedny/powergrim-server/grimoire"
+)
+
+type renameScript struct {
+	Action string `json:"action"`
+	Script string `json:"script"`
+}
+
+func init() {
+	grimoire.RegisterAction(grimoire.ActionType[renameScript]{
+		Name: "renameScript",
+		Validate: func(action renameScript) error {
+			if action.Script == "" {
+				return grimoire.ErrInvalidAction
+			}
+			return nil
+		},
+		Apply: func(game grimoire.Game, action renameScript) (grimoire.Game, error) {
+			game.Script = action.Script
+			return game, nil
+		},
+	})
+}
+
+func TestRegisteredAction(t *testing.T) {
+	var wa grimoire.WrappedAction
+	if err := json.Unmarshal([]byte(`{"action":"renameScript","script":"tb"}`), &wa); err != nil {
+		t.Fatal(err)
+	}
+	got, err := grimoire.Game{Script: "bmr"}.ApplyAction(wa.Action)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Script != "tb" {
+		t.Fatalf("ApplyAction() returned script %q; expected tb", got.Script)
+	}
+	if err := json.Unmarshal([]byte(`{"action":"renameScript"}`), &wa); err != grimoire.ErrInvalidAction {
+		t.Fatalf("json.Unmarshal() returned %v; expected %v", err, grimoire.ErrInvalidAction)
+	}
+	if _, err := got.InverseAction(wa.Action); err != grimoire.ErrNoInverse {
+		t.Fatalf("InverseAction() returned %v; expected %v", err, grimoire.ErrNoInverse)
+	}
+}
+
+type shout string
+
+func init() {
+	grimoire.RegisterAction(grimoire.ActionType[shout]{
+		Name: "shout",
+		Decode: func(data []byte) (shout, error) {
+			var action struct {
+				Text string `json:"text"`
+			}
+			err := json.Unmarshal(data, &action)
+			return shout(strings.ToUpper(action.Text)), err
+		},
+		Apply: func(game grimoire.Game, action shout) (grimoire.Game, error) {
+			game.Script = string(action)
+			return game, nil
+		},
+	})
+}
+
+func TestRegisteredActionDecode(t *testing.T) {
+	var wa grimoire.WrappedAction
+	if err := json.Unmarshal([]byte(`{"action":"shout","text":"tb"}`), &wa); err != nil {
+		t.Fatal(err)
+	}
+	if wa.Action != shout("TB") {
+		t.Fatalf("json.Unmarshal() decoded %#v; expected %#v", wa.Action, shout("TB"))
+	}
+}
+
+func TestRegisterActionTwice(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("RegisterAction() did not panic for a registered name")
+		}
+	}()
+	grimoire.RegisterAction(grimoire.ActionType[struct{}]{
+		Name:  "addPlayer",
+		Apply: func(game grimoire.Game, action struct{}) (grimoire.Game, error) { return game, nil },
+	})
+}
+
+func TestInverseAction(t *testing.T) {
+	game := grimoire.Game{
+		Players: []grimoire.Player{{Id: 1}, {Id: 2}, {Id: 3}},
+		Reminders: []grimoire.Reminder{
+			{Character: "monk", Token: "protected", Position: grimoire.ReminderPosition{2}, ExpiresAt: 4},
+		},
+	}
+	actions := []any{
+		grimoire.AddReminder{Action: "addReminder", Character: "imp", Token: "dead", Position: grimoire.ReminderPosition{3, 2}},
+		grimoire.MoveReminder{Action: "moveReminder", Character: "monk", Token: "protected", FromPosition: grimoire.ReminderPosition{2}, ToPosition: grimoire.ReminderPosition{1, 3}},
+	}
+	for _, action := range actions {
+		inverse, err := game.InverseAction(action)
+		if err != nil {
+			t.Fatalf("InverseAction(%#v) returned error %v", action, err)
+		}
+		applied, err := game.ApplyAction(action)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := applied.ApplyAction(inverse)
+		if err != nil {
+			t.Fatalf("applying inverse of %#v returned error %v", action, err)
+		}
+		if !reflect.DeepEqual(got, game) {
+			t.Fatalf("applying inverse of %#v returned %#v; expected %#v", action, got, game)
+		}
+	}
+}
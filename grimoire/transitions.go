@@ -1,4 +1,4 @@
-package main
+package grimoire
 
 import (
 	"errors"
@@ -16,29 +16,10 @@ var (
 	ErrMovingWithSharedReminder = errors.New("moving a player must not disturb a shared reminder token")
 	ErrExistingReminder         = errors.New("reminder must be present")
 	ErrReminderPosition         = errors.New("position must be 0, player id, or array with 2 adjacent player ids")
+	ErrReminderExpiry           = errors.New("until must be absent, dawn or dusk, or untilPhase must be a later phase")
+	ErrPhase                    = errors.New("phase must be absent or a later phase")
 )
 
-func (game Game) ApplyAction(action any) (Game, error) {
-	switch action := action.(type) {
-	case AddPlayer:
-		return game.AddPlayer(action)
-	case RemovePlayer:
-		return game.RemovePlayer(action)
-	case MovePlayer:
-		return game.MovePlayer(action)
-	case UpdatePlayer:
-		return game.UpdatePlayer(action)
-	case AddReminder:
-		return game.AddReminder(action)
-	case RemoveReminder:
-		return game.RemoveReminder(action)
-	case MoveReminder:
-		return game.MoveReminder(action)
-	default:
-		return Game{}, ErrInvalidAction
-	}
-}
-
 func (game Game) AddPlayer(addPlayer AddPlayer) (Game, error) {
 	if addPlayer.Id == 0 {
 		return Game{}, ErrIdLength
@@ -59,7 +40,7 @@ func (game Game) AddPlayer(addPlayer AddPlayer) (Game, error) {
 		game.Players = append(slices.Clone(game.Players), player)
 		return game, nil
 	}
-	afterPlayerIdx := slices.IndexFunc(game.Players, playerWithId(addPlayer.AfterPlayer))
+	afterPlayerIdx := slices.IndexFunc(game.Players, PlayerWithId(addPlayer.AfterPlayer))
 	if afterPlayerIdx == -1 {
 		return Game{}, ErrOptionalAfterPlayer
 	}
@@ -68,7 +49,7 @@ func (game Game) AddPlayer(addPlayer AddPlayer) (Game, error) {
 }
 
 func (game Game) RemovePlayer(removePlayer RemovePlayer) (Game, error) {
-	playerIdx := slices.IndexFunc(game.Players, playerWithId(removePlayer.Id))
+	playerIdx := slices.IndexFunc(game.Players, PlayerWithId(removePlayer.Id))
 	if playerIdx == -1 {
 		return Game{}, ErrExistingId
 	}
@@ -92,20 +73,20 @@ func (game Game) MovePlayer(movePlayer MovePlayer) (Game, error) {
 	if movePlayer.Id == movePlayer.AfterPlayer {
 		return Game{}, ErrDistinctIdAfterPlayer
 	}
-	playerIdx := slices.IndexFunc(game.Players, playerWithId(movePlayer.Id))
+	playerIdx := slices.IndexFunc(game.Players, PlayerWithId(movePlayer.Id))
 	if playerIdx == -1 {
 		return Game{}, ErrExistingId
 	}
 	player := game.Players[playerIdx]
 	game.Players = append(slices.Clone(game.Players)[:playerIdx], game.Players[playerIdx+1:]...)
-	afterPlayerIdx := slices.IndexFunc(game.Players, playerWithId(movePlayer.AfterPlayer))
+	afterPlayerIdx := slices.IndexFunc(game.Players, PlayerWithId(movePlayer.AfterPlayer))
 	if afterPlayerIdx == -1 {
 		return Game{}, ErrRequiredAfterPlayer
 	}
 	game.Players = slices.Insert(game.Players, afterPlayerIdx+1, player)
 	var reminders []Reminder
 	for reminderIdx, reminder := range game.Reminders {
-		cPos, err := game.canonicalReminderPosition(reminder.Position)
+		cPos, err := game.CanonicalReminderPosition(reminder.Position)
 		if err != nil {
 			return Game{}, ErrMovingWithSharedReminder
 		}
@@ -121,7 +102,7 @@ func (game Game) MovePlayer(movePlayer MovePlayer) (Game, error) {
 }
 
 func (game Game) UpdatePlayer(updatePlayer UpdatePlayer) (Game, error) {
-	playerIdx := slices.IndexFunc(game.Players, playerWithId(updatePlayer.Id))
+	playerIdx := slices.IndexFunc(game.Players, PlayerWithId(updatePlayer.Id))
 	if playerIdx == -1 {
 		return Game{}, ErrExistingId
 	}
@@ -140,12 +121,17 @@ func (game Game) UpdatePlayer(updatePlayer UpdatePlayer) (Game, error) {
 		player.FirstNight = true
 	}
 	player.Alignment = updatePlayer.Alignment
+	game.Players = slices.Clone(game.Players)
 	game.Players[playerIdx] = player
 	return game, nil
 }
 
 func (game Game) AddReminder(addReminder AddReminder) (Game, error) {
-	cPos, err := game.canonicalReminderPosition(addReminder.Position)
+	cPos, err := game.CanonicalReminderPosition(addReminder.Position)
+	if err != nil {
+		return Game{}, err
+	}
+	expiresAt, err := game.reminderExpiry(addReminder.Until, addReminder.UntilPhase)
 	if err != nil {
 		return Game{}, err
 	}
@@ -153,12 +139,80 @@ func (game Game) AddReminder(addReminder AddReminder) (Game, error) {
 		Character: addReminder.Character,
 		Token:     addReminder.Token,
 		Position:  cPos,
+		ExpiresAt: expiresAt,
 	})
 	return game, nil
 }
 
+// reminderExpiry returns the phase at the start of which a reminder is
+// removed. Phase 0 is the setup, odd phases are nights and even phases are
+// days, so dawn is the start of the next even phase and dusk of the next odd
+// one.
+func (game Game) reminderExpiry(until string, untilPhase int) (int, error) {
+	switch {
+	case until != "" && untilPhase != 0:
+		return 0, ErrReminderExpiry
+	case until == "dawn":
+		return game.Phase + 2 - game.Phase%2, nil
+	case until == "dusk":
+		return game.Phase + 1 + game.Phase%2, nil
+	case until != "":
+		return 0, ErrReminderExpiry
+	case untilPhase != 0 && untilPhase <= game.Phase:
+		return 0, ErrReminderExpiry
+	default:
+		return untilPhase, nil
+	}
+}
+
+// ValidatePhase checks that the phase of a game is not negative and that its
+// reminders expire in a later phase, like reminderExpiry does for reminders
+// that are added to it.
+func (game Game) ValidatePhase() error {
+	if game.Phase < 0 {
+		return ErrPhase
+	}
+	for _, reminder := range game.Reminders {
+		if reminder.ExpiresAt != 0 && reminder.ExpiresAt <= game.Phase {
+			return ErrReminderExpiry
+		}
+	}
+	return nil
+}
+
+func (game Game) AdvancePhase(advancePhase AdvancePhase) (Game, error) {
+	phase := game.Phase + 1
+	if advancePhase.Phase != 0 {
+		if advancePhase.Phase <= game.Phase {
+			return Game{}, ErrPhase
+		}
+		phase = advancePhase.Phase
+	}
+	game.Phase = phase
+	reminders := make([]Reminder, 0, len(game.Reminders))
+	for _, reminder := range game.Reminders {
+		if reminder.ExpiresAt == 0 || reminder.ExpiresAt > phase {
+			reminders = append(reminders, reminder)
+		}
+	}
+	game.Reminders = reminders
+	return game, nil
+}
+
+// ExpiredReminders returns the reminders of game that are no longer present
+// in next because they expired.
+func (game Game) ExpiredReminders(next Game) []Reminder {
+	var expired []Reminder
+	for _, reminder := range game.Reminders {
+		if reminder.ExpiresAt != 0 && reminder.ExpiresAt <= next.Phase {
+			expired = append(expired, reminder)
+		}
+	}
+	return expired
+}
+
 func (game Game) RemoveReminder(removeReminder RemoveReminder) (Game, error) {
-	cPos, err := game.canonicalReminderPosition(removeReminder.Position)
+	cPos, err := game.CanonicalReminderPosition(removeReminder.Position)
 	if err != nil {
 		return Game{}, err
 	}
@@ -175,7 +229,7 @@ func (game Game) RemoveReminder(removeReminder RemoveReminder) (Game, error) {
 }
 
 func (game Game) MoveReminder(moveReminder MoveReminder) (Game, error) {
-	cPos, err := game.canonicalReminderPosition(moveReminder.FromPosition)
+	cPos, err := game.CanonicalReminderPosition(moveReminder.FromPosition)
 	if err != nil {
 		return Game{}, err
 	}
@@ -187,22 +241,23 @@ func (game Game) MoveReminder(moveReminder MoveReminder) (Game, error) {
 	if reminderIdx == -1 {
 		return Game{}, ErrExistingReminder
 	}
-	cPos, err = game.canonicalReminderPosition(moveReminder.ToPosition)
+	cPos, err = game.CanonicalReminderPosition(moveReminder.ToPosition)
 	if err != nil {
 		return Game{}, err
 	}
+	game.Reminders = slices.Clone(game.Reminders)
 	game.Reminders[reminderIdx].Position = cPos
 	return game, nil
 }
 
-func (game Game) canonicalReminderPosition(position ReminderPosition) (ReminderPosition, error) {
+func (game Game) CanonicalReminderPosition(position ReminderPosition) (ReminderPosition, error) {
 	switch {
 	case position[1] != 0:
 		if position[0] == position[1] {
 			return ReminderPosition{}, ErrReminderPosition
 		}
-		player1Idx := slices.IndexFunc(game.Players, playerWithId(position[0]))
-		player2Idx := slices.IndexFunc(game.Players, playerWithId(position[1]))
+		player1Idx := slices.IndexFunc(game.Players, PlayerWithId(position[0]))
+		player2Idx := slices.IndexFunc(game.Players, PlayerWithId(position[1]))
 		switch {
 		case player1Idx == -1 || player2Idx == -1:
 			return ReminderPosition{}, ErrReminderPosition
@@ -225,7 +280,7 @@ func (game Game) canonicalReminderPosition(position ReminderPosition) (ReminderP
 	}
 }
 
-func playerWithId(id int) func(Player) bool {
+func PlayerWithId(id int) func(Player) bool {
 	return func(p Player) bool { return p.Id == id }
 }
 
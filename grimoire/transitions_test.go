@@ -0,0 +1,682 @@
+package grimoire_test
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/phedny/powergrim-server/grimoire"
+)
+
+func TestAddPlayer(t *testing.T) {
+	game := grimoire.Game{
+		Players: []grimoire.Player{
+			{Id: 1},
+		},
+	}
+
+	got, err := game.AddPlayer(grimoire.AddPlayer{Id: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := grimoire.Game{
+		Players: []grimoire.Player{
+			{Id: 1},
+			{Id: 2, Alive: true, FirstNight: true},
+		},
+	}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("AddPlayer() returned %#v; expected %#v", got, expected)
+	}
+}
+
+func TestAddPlayerAfterPlayer(t *testing.T) {
+	game := grimoire.Game{
+		Players: []grimoire.Player{
+			{Id: 1},
+			{Id: 2},
+			{Id: 3},
+		},
+	}
+
+	got, err := game.AddPlayer(grimoire.AddPlayer{Id: 4, AfterPlayer: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := grimoire.Game{
+		Players: []grimoire.Player{
+			{Id: 1},
+			{Id: 4, Alive: true, FirstNight: true},
+			{Id: 2},
+			{Id: 3},
+		},
+	}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("AddPlayer() returned %#v; expected %#v", got, expected)
+	}
+}
+
+func TestAddPlayerUniqueId(t *testing.T) {
+	game := grimoire.Game{
+		Players: []grimoire.Player{
+			{Id: 1},
+		},
+	}
+
+	got, err := game.AddPlayer(grimoire.AddPlayer{Id: 1})
+	if err != grimoire.ErrUniqueId {
+		t.Fatalf("AddPlayer() returned (%#v, %s); expected error %s", got, err, grimoire.ErrUniqueId)
+	}
+}
+
+func TestAddPlayerInvalidAfterPlayer(t *testing.T) {
+	game := grimoire.Game{
+		Players: []grimoire.Player{
+			{Id: 1},
+		},
+	}
+
+	got, err := game.AddPlayer(grimoire.AddPlayer{Id: 2, AfterPlayer: 3})
+	if err != grimoire.ErrOptionalAfterPlayer {
+		t.Fatalf("AddPlayer() returned (%#v, %s); expected error %s", got, err, grimoire.ErrOptionalAfterPlayer)
+	}
+}
+
+func TestRemovePlayer(t *testing.T) {
+	game := grimoire.Game{
+		Players: []grimoire.Player{
+			{Id: 1},
+			{Id: 2, Character: "Something"},
+			{Id: 3},
+		},
+		Reminders: []grimoire.Reminder{
+			{Character: "Something", Token: "Some Token", Position: grimoire.ReminderPosition{1}},
+			{Character: "Other", Token: "Must Stay", Position: grimoire.ReminderPosition{1}},
+			{Character: "Other", Token: "Some Token", Position: grimoire.ReminderPosition{2}},
+			{Character: "Other", Token: "Shared Token", Position: grimoire.ReminderPosition{2, 3}},
+		},
+	}
+
+	got, err := game.RemovePlayer(grimoire.RemovePlayer{Id: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := grimoire.Game{
+		Players: []grimoire.Player{
+			{Id: 1},
+			{Id: 3},
+		},
+		Reminders: []grimoire.Reminder{
+			{Character: "Other", Token: "Must Stay", Position: grimoire.ReminderPosition{1}},
+		},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("RemovePlayer() returned %#v; expected %#v", got, expected)
+	}
+}
+
+func TestMovePlayer(t *testing.T) {
+	game := grimoire.Game{
+		Players: []grimoire.Player{
+			{Id: 1},
+			{Id: 2},
+			{Id: 3},
+			{Id: 4},
+		},
+		Reminders: []grimoire.Reminder{
+			{Character: "Something", Token: "Some Token", Position: grimoire.ReminderPosition{2}},
+			{Character: "Something", Token: "Other Token", Position: grimoire.ReminderPosition{4, 1}},
+		},
+	}
+
+	got, err := game.MovePlayer(grimoire.MovePlayer{Id: 1, AfterPlayer: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := grimoire.Game{
+		Players: []grimoire.Player{
+			{Id: 2},
+			{Id: 3},
+			{Id: 1},
+			{Id: 4},
+		},
+		Reminders: []grimoire.Reminder{
+			{Character: "Something", Token: "Some Token", Position: grimoire.ReminderPosition{2}},
+			{Character: "Something", Token: "Other Token", Position: grimoire.ReminderPosition{1, 4}},
+		},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("MovePlayer() returned %#v; expected %#v", got, expected)
+	}
+}
+
+func TestMovePlayerBreakingSharedToken(t *testing.T) {
+	game := grimoire.Game{
+		Players: []grimoire.Player{
+			{Id: 1},
+			{Id: 2},
+			{Id: 3},
+			{Id: 4},
+		},
+		Reminders: []grimoire.Reminder{
+			{Character: "Something", Token: "Some Token", Position: grimoire.ReminderPosition{2}},
+			{Character: "Something", Token: "Other Token", Position: grimoire.ReminderPosition{3, 4}},
+		},
+	}
+
+	got, err := game.MovePlayer(grimoire.MovePlayer{Id: 1, AfterPlayer: 3})
+	if err != grimoire.ErrMovingWithSharedReminder {
+		t.Fatalf("MovePlayer() returned (%#v, %s); expected error %s", got, err, grimoire.ErrMovingWithSharedReminder)
+	}
+}
+
+func TestUpdatePlayer(t *testing.T) {
+	game := grimoire.Game{
+		Players: []grimoire.Player{
+			{Id: 1},
+			{Id: 2},
+			{Id: 3},
+		},
+	}
+
+	got, err := game.UpdatePlayer(grimoire.UpdatePlayer{Id: 1, Character: "Something", Alignment: "good"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := grimoire.Game{
+		Players: []grimoire.Player{
+			{Id: 1, Character: "Something", Alignment: "good", FirstNight: true},
+			{Id: 2},
+			{Id: 3},
+		},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("UpdatePlayer() returned %#v; expected %#v", got, expected)
+	}
+}
+
+func TestUpdatePlayeCharacterChange(t *testing.T) {
+	game := grimoire.Game{
+		Players: []grimoire.Player{
+			{Id: 1, Character: "Something"},
+			{Id: 2},
+			{Id: 3},
+		},
+		Reminders: []grimoire.Reminder{
+			{Character: "Something", Token: "Some Token", Position: grimoire.ReminderPosition{2}},
+			{Character: "Other Character", Token: "Other Token", Position: grimoire.ReminderPosition{2}},
+		},
+	}
+
+	got, err := game.UpdatePlayer(grimoire.UpdatePlayer{Id: 1, Character: "New Character", Alignment: "good"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := grimoire.Game{
+		Players: []grimoire.Player{
+			{Id: 1, Character: "New Character", Alignment: "good", FirstNight: true},
+			{Id: 2},
+			{Id: 3},
+		},
+		Reminders: []grimoire.Reminder{
+			{Character: "Other Character", Token: "Other Token", Position: grimoire.ReminderPosition{2}},
+		},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("UpdatePlayer() returned %#v; expected %#v", got, expected)
+	}
+}
+
+func TestAddCentralReminder(t *testing.T) {
+	game := grimoire.Game{
+		Players: []grimoire.Player{
+			{Id: 1},
+		},
+	}
+
+	got, err := game.AddReminder(grimoire.AddReminder{Character: "Something", Token: "Some Token", Position: grimoire.ReminderPosition{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := grimoire.Game{
+		Players: []grimoire.Player{
+			{Id: 1},
+		},
+		Reminders: []grimoire.Reminder{
+			{Character: "Something", Token: "Some Token", Position: grimoire.ReminderPosition{}},
+		},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("AddReminder() returned %#v; expected %#v", got, expected)
+	}
+}
+
+func TestAddPlayerReminder(t *testing.T) {
+	game := grimoire.Game{
+		Players: []grimoire.Player{
+			{Id: 1},
+		},
+	}
+
+	got, err := game.AddReminder(grimoire.AddReminder{Character: "Something", Token: "Some Token", Position: grimoire.ReminderPosition{1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := grimoire.Game{
+		Players: []grimoire.Player{
+			{Id: 1},
+		},
+		Reminders: []grimoire.Reminder{
+			{Character: "Something", Token: "Some Token", Position: grimoire.ReminderPosition{1}},
+		},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("AddReminder() returned %#v; expected %#v", got, expected)
+	}
+}
+
+func TestAddReminderToNonExistingPlayer(t *testing.T) {
+	game := grimoire.Game{
+		Players: []grimoire.Player{
+			{Id: 1},
+		},
+	}
+
+	got, err := game.AddReminder(grimoire.AddReminder{Character: "Something", Token: "Some Token", Position: grimoire.ReminderPosition{2}})
+	if err != grimoire.ErrReminderPosition {
+		t.Fatalf("AddReminder() returned (%#v, %s); expected error %s", got, err, grimoire.ErrReminderPosition)
+	}
+}
+
+func TestAddSharedPlayerReminder(t *testing.T) {
+	game := grimoire.Game{
+		Players: []grimoire.Player{
+			{Id: 1},
+			{Id: 2},
+			{Id: 3},
+		},
+	}
+
+	got, err := game.AddReminder(grimoire.AddReminder{Character: "Something", Token: "Some Token", Position: grimoire.ReminderPosition{2, 3}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := grimoire.Game{
+		Players: []grimoire.Player{
+			{Id: 1},
+			{Id: 2},
+			{Id: 3},
+		},
+		Reminders: []grimoire.Reminder{
+			{Character: "Something", Token: "Some Token", Position: grimoire.ReminderPosition{2, 3}},
+		},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("AddReminder() returned %#v; expected %#v", got, expected)
+	}
+}
+
+func TestAddSharedPlayerReminderSwitchedOrder(t *testing.T) {
+	game := grimoire.Game{
+		Players: []grimoire.Player{
+			{Id: 1},
+			{Id: 2},
+			{Id: 3},
+		},
+	}
+
+	got, err := game.AddReminder(grimoire.AddReminder{Character: "Something", Token: "Some Token", Position: grimoire.ReminderPosition{1, 3}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := grimoire.Game{
+		Players: []grimoire.Player{
+			{Id: 1},
+			{Id: 2},
+			{Id: 3},
+		},
+		Reminders: []grimoire.Reminder{
+			{Character: "Something", Token: "Some Token", Position: grimoire.ReminderPosition{3, 1}},
+		},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("AddReminder() returned %#v; expected %#v", got, expected)
+	}
+}
+
+func TestAddReminderToNonExistingSharedPlayer(t *testing.T) {
+	game := grimoire.Game{
+		Players: []grimoire.Player{
+			{Id: 1},
+			{Id: 2},
+			{Id: 3},
+		},
+	}
+
+	got, err := game.AddReminder(grimoire.AddReminder{Character: "Something", Token: "Some Token", Position: grimoire.ReminderPosition{2, 4}})
+	if err != grimoire.ErrReminderPosition {
+		t.Fatalf("AddReminder() returned (%#v, %s); expected error %s", got, err, grimoire.ErrReminderPosition)
+	}
+}
+
+func TestAddReminderToNonNeighbouringSharedPlayer(t *testing.T) {
+	game := grimoire.Game{
+		Players: []grimoire.Player{
+			{Id: 1},
+			{Id: 2},
+			{Id: 3},
+			{Id: 4},
+		},
+	}
+
+	got, err := game.AddReminder(grimoire.AddReminder{Character: "Something", Token: "Some Token", Position: grimoire.ReminderPosition{1, 3}})
+	if err != grimoire.ErrReminderPosition {
+		t.Fatalf("AddReminder() returned (%#v, %s); expected error %s", got, err, grimoire.ErrReminderPosition)
+	}
+}
+
+func TestRemoveReminder(t *testing.T) {
+	game := grimoire.Game{
+		Players: []grimoire.Player{
+			{Id: 1},
+			{Id: 2},
+			{Id: 3},
+		},
+		Reminders: []grimoire.Reminder{
+			{Character: "Something", Token: "Some Token", Position: grimoire.ReminderPosition{2, 3}},
+		},
+	}
+
+	got, err := game.RemoveReminder(grimoire.RemoveReminder{Character: "Something", Token: "Some Token", Position: grimoire.ReminderPosition{3, 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := grimoire.Game{
+		Players: []grimoire.Player{
+			{Id: 1},
+			{Id: 2},
+			{Id: 3},
+		},
+		Reminders: []grimoire.Reminder{},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("RemoveReminder() returned %#v; expected %#v", got, expected)
+	}
+}
+
+func TestRemoveNonExistingReminder(t *testing.T) {
+	game := grimoire.Game{
+		Players: []grimoire.Player{
+			{Id: 1},
+			{Id: 2},
+			{Id: 3},
+		},
+		Reminders: []grimoire.Reminder{
+			{Character: "Something", Token: "Some Token", Position: grimoire.ReminderPosition{2, 3}},
+		},
+	}
+
+	got, err := game.RemoveReminder(grimoire.RemoveReminder{Character: "Something", Token: "Some Token", Position: grimoire.ReminderPosition{1}})
+	if err != grimoire.ErrExistingReminder {
+		t.Fatalf("RemoveReminder() returned (%#v, %s); expected error %s", got, err, grimoire.ErrExistingReminder)
+	}
+}
+
+func TestMoveReminder(t *testing.T) {
+	game := grimoire.Game{
+		Players: []grimoire.Player{
+			{Id: 1},
+			{Id: 2},
+			{Id: 3},
+		},
+		Reminders: []grimoire.Reminder{
+			{Character: "Something", Token: "Some Token", Position: grimoire.ReminderPosition{2, 3}},
+		},
+	}
+
+	got, err := game.MoveReminder(grimoire.MoveReminder{Character: "Something", Token: "Some Token", FromPosition: grimoire.ReminderPosition{2, 3}, ToPosition: grimoire.ReminderPosition{1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := grimoire.Game{
+		Players: []grimoire.Player{
+			{Id: 1},
+			{Id: 2},
+			{Id: 3},
+		},
+		Reminders: []grimoire.Reminder{
+			{Character: "Something", Token: "Some Token", Position: grimoire.ReminderPosition{1}},
+		},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("MoveReminder() returned %#v; expected %#v", got, expected)
+	}
+}
+
+func TestMoveNonExistingReminder(t *testing.T) {
+	game := grimoire.Game{
+		Players: []grimoire.Player{
+			{Id: 1},
+			{Id: 2},
+			{Id: 3},
+		},
+		Reminders: []grimoire.Reminder{
+			{Character: "Something", Token: "Some Token", Position: grimoire.ReminderPosition{2, 3}},
+		},
+	}
+
+	got, err := game.MoveReminder(grimoire.MoveReminder{Character: "Something", Token: "Some Token", FromPosition: grimoire.ReminderPosition{1}, ToPosition: grimoire.ReminderPosition{}})
+	if err != grimoire.ErrExistingReminder {
+		t.Fatalf("MoveReminder() returned (%#v, %s); expected error %s", got, err, grimoire.ErrExistingReminder)
+	}
+}
+
+func TestMovePReminderToNonExistingSharedPlayer(t *testing.T) {
+	game := grimoire.Game{
+		Players: []grimoire.Player{
+			{Id: 1},
+			{Id: 2},
+			{Id: 3},
+		},
+		Reminders: []grimoire.Reminder{
+			{Character: "Something", Token: "Some Token", Position: grimoire.ReminderPosition{2, 3}},
+		},
+	}
+
+	got, err := game.MoveReminder(grimoire.MoveReminder{Character: "Something", Token: "Some Token", FromPosition: grimoire.ReminderPosition{2, 3}, ToPosition: grimoire.ReminderPosition{2, 4}})
+	if err != grimoire.ErrReminderPosition {
+		t.Fatalf("MoveReminder() returned (%#v, %s); expected error %s", got, err, grimoire.ErrReminderPosition)
+	}
+}
+
+func TestMoveReminderToNonNeighbouringSharedPlayer(t *testing.T) {
+	game := grimoire.Game{
+		Players: []grimoire.Player{
+			{Id: 1},
+			{Id: 2},
+			{Id: 3},
+			{Id: 4},
+		},
+		Reminders: []grimoire.Reminder{
+			{Character: "Something", Token: "Some Token", Position: grimoire.ReminderPosition{2, 3}},
+		},
+	}
+
+	got, err := game.MoveReminder(grimoire.MoveReminder{Character: "Something", Token: "Some Token", FromPosition: grimoire.ReminderPosition{2, 3}, ToPosition: grimoire.ReminderPosition{1, 3}})
+	if err != grimoire.ErrReminderPosition {
+		t.Fatalf("AddReminder() returned (%#v, %s); expected error %s", got, err, grimoire.ErrReminderPosition)
+	}
+}
+
+func cloneGame(game grimoire.Game) grimoire.Game {
+	game.Players = slices.Clone(game.Players)
+	game.Reminders = slices.Clone(game.Reminders)
+	return game
+}
+
+// withSpareCapacity gives the slices of game room to grow, so that a
+// transition appending in place would write into the backing arrays.
+func withSpareCapacity(game grimoire.Game) grimoire.Game {
+	game.Players = append(make([]grimoire.Player, 0, len(game.Players)+4), game.Players...)
+	game.Reminders = append(make([]grimoire.Reminder, 0, len(game.Reminders)+4), game.Reminders...)
+	return game
+}
+
+func TestApplyActionDoesNotMutateGame(t *testing.T) {
+	game := grimoire.Game{
+		Players: []grimoire.Player{
+			{Id: 1, Character: "washerwoman", Alive: true},
+			{Id: 2, Character: "imp", Alive: true},
+			{Id: 3, Alive: true},
+			{Id: 4, Character: "poisoner", Alive: true},
+		},
+		Reminders: []grimoire.Reminder{
+			{Character: "washerwoman", Token: "townsfolk", Position: grimoire.ReminderPosition{2}},
+			{Character: "poisoner", Token: "poisoned", Position: grimoire.ReminderPosition{1, 2}},
+			{Character: "imp", Token: "dead", Position: grimoire.ReminderPosition{3}},
+		},
+	}
+	actions := []any{
+		grimoire.AddPlayer{Id: 5},
+		grimoire.AddPlayer{Id: 5, AfterPlayer: 2},
+		grimoire.AddPlayer{Id: 1},
+		grimoire.RemovePlayer{Id: 2},
+		grimoire.RemovePlayer{Id: 9},
+		grimoire.MovePlayer{Id: 4, AfterPlayer: 1},
+		grimoire.MovePlayer{Id: 3, AfterPlayer: 9},
+		grimoire.MovePlayer{Id: 2, AfterPlayer: 4},
+		grimoire.UpdatePlayer{Id: 1, Character: "chef"},
+		grimoire.UpdatePlayer{Id: 3, Alignment: "evil"},
+		grimoire.UpdatePlayer{Id: 9},
+		grimoire.AddReminder{Character: "imp", Token: "dead", Position: grimoire.ReminderPosition{4}},
+		grimoire.AddReminder{Character: "imp", Token: "dead", Position: grimoire.ReminderPosition{1, 3}},
+		grimoire.RemoveReminder{Character: "imp", Token: "dead", Position: grimoire.ReminderPosition{3}},
+		grimoire.RemoveReminder{Character: "imp", Token: "dead", Position: grimoire.ReminderPosition{4}},
+		grimoire.MoveReminder{Character: "imp", Token: "dead", FromPosition: grimoire.ReminderPosition{3}, ToPosition: grimoire.ReminderPosition{4}},
+		grimoire.MoveReminder{Character: "imp", Token: "dead", FromPosition: grimoire.ReminderPosition{3}, ToPosition: grimoire.ReminderPosition{1, 3}},
+		grimoire.MoveReminder{Character: "poisoner", Token: "poisoned", FromPosition: grimoire.ReminderPosition{2, 1}, ToPosition: grimoire.ReminderPosition{3, 4}},
+		grimoire.AddReminder{Character: "imp", Token: "dead", Position: grimoire.ReminderPosition{4}, Until: "dawn"},
+		grimoire.AdvancePhase{},
+		grimoire.AdvancePhase{Phase: -1},
+		struct{}{},
+	}
+	for _, action := range actions {
+		for _, input := range []grimoire.Game{game, withSpareCapacity(game)} {
+			expected := cloneGame(input)
+			got, err := input.ApplyAction(action)
+			if !reflect.DeepEqual(input, expected) {
+				t.Fatalf("ApplyAction(%#v) changed the game to %#v", action, input)
+			}
+			if err != nil {
+				continue
+			}
+			before := cloneGame(got)
+			if _, err := input.ApplyAction(action); err != nil {
+				t.Fatalf("ApplyAction(%#v) failed when repeated: %s", action, err)
+			}
+			if !reflect.DeepEqual(got, before) {
+				t.Fatalf("repeating ApplyAction(%#v) changed an earlier result", action)
+			}
+		}
+	}
+}
+
+func TestReminderExpiry(t *testing.T) {
+	game := grimoire.Game{
+		Phase:   2,
+		Players: []grimoire.Player{{Id: 1}},
+	}
+	tests := []struct {
+		until      string
+		untilPhase int
+		expiresAt  int
+		err        error
+	}{
+		{"", 0, 0, nil},
+		{"dawn", 0, 4, nil},
+		{"dusk", 0, 3, nil},
+		{"", 5, 5, nil},
+		{"", 2, 0, grimoire.ErrReminderExpiry},
+		{"noon", 0, 0, grimoire.ErrReminderExpiry},
+		{"dawn", 5, 0, grimoire.ErrReminderExpiry},
+	}
+	for _, test := range tests {
+		got, err := game.AddReminder(grimoire.AddReminder{Character: "monk", Token: "protected", Position: grimoire.ReminderPosition{1}, Until: test.until, UntilPhase: test.untilPhase})
+		if err != test.err {
+			t.Fatalf("AddReminder(%q, %d) returned error %v; expected %v", test.until, test.untilPhase, err, test.err)
+		}
+		if err == nil && got.Reminders[0].ExpiresAt != test.expiresAt {
+			t.Fatalf("AddReminder(%q, %d) expires at %d; expected %d", test.until, test.untilPhase, got.Reminders[0].ExpiresAt, test.expiresAt)
+		}
+	}
+
+	game.Phase = 1
+	if got, _ := game.AddReminder(grimoire.AddReminder{Character: "monk", Token: "protected", Until: "dawn"}); got.Reminders[0].ExpiresAt != 2 {
+		t.Fatalf("AddReminder() at night expires at %d; expected 2", got.Reminders[0].ExpiresAt)
+	}
+}
+
+func TestAdvancePhase(t *testing.T) {
+	game := grimoire.Game{
+		Phase: 1,
+		Reminders: []grimoire.Reminder{
+			{Character: "monk", Token: "protected", ExpiresAt: 2},
+			{Character: "poisoner", Token: "poisoned", ExpiresAt: 3},
+			{Character: "imp", Token: "dead"},
+		},
+	}
+	got, err := game.AdvancePhase(grimoire.AdvancePhase{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := grimoire.Game{
+		Phase:     2,
+		Reminders: game.Reminders[1:],
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("AdvancePhase() returned %#v; expected %#v", got, expected)
+	}
+	if expired := game.ExpiredReminders(got); !reflect.DeepEqual(expired, game.Reminders[:1]) {
+		t.Fatalf("ExpiredReminders() returned %#v", expired)
+	}
+
+	got, err = game.AdvancePhase(grimoire.AdvancePhase{Phase: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Phase != 5 || len(got.Reminders) != 1 {
+		t.Fatalf("AdvancePhase() returned %#v", got)
+	}
+	if _, err := game.AdvancePhase(grimoire.AdvancePhase{Phase: 1}); err != grimoire.ErrPhase {
+		t.Fatalf("AdvancePhase() returned error %v; expected %v", err, grimoire.ErrPhase)
+	}
+}
+
+func TestValidatePhase(t *testing.T) {
+	tests := []struct {
+		game     grimoire.Game
+		expected error
+	}{
+		{grimoire.Game{Phase: 3, Reminders: []grimoire.Reminder{{ExpiresAt: 4}, {}}}, nil},
+		{grimoire.Game{Phase: -1}, grimoire.ErrPhase},
+		{grimoire.Game{Phase: 3, Reminders: []grimoire.Reminder{{ExpiresAt: 3}}}, grimoire.ErrReminderExpiry},
+		{grimoire.Game{Reminders: []grimoire.Reminder{{ExpiresAt: -2}}}, grimoire.ErrReminderExpiry},
+	}
+	for _, test := range tests {
+		if err := test.game.ValidatePhase(); err != test.expected {
+			t.Errorf("ValidatePhase() of %#v returned %v; expected %v", test.game, err, test.expected)
+		}
+	}
+}
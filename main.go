@@ -0,0 +1,7 @@
+package main
+
+import "github.com/phedny/powergrim-server/server"
+
+func main() {
+	server.Main()
+}
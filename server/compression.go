@@ -0,0 +1,100 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const compressionThreshold = 1 << 10
+
+var precompressedEncodings = []string{"gzip", "deflate"}
+
+func compress(encoding string, data []byte) []byte {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w, _ = gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	case "deflate":
+		// The deflate content coding is the zlib format of RFC 1950, not
+		// raw DEFLATE.
+		w, _ = zlib.NewWriterLevel(&buf, zlib.BestCompression)
+	default:
+		return nil
+	}
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+func precompress(data []byte) map[string][]byte {
+	variants := make(map[string][]byte)
+	if len(data) < compressionThreshold {
+		return variants
+	}
+	for _, encoding := range precompressedEncodings {
+		if compressed := compress(encoding, data); len(compressed) < len(data) {
+			variants[encoding] = compressed
+		}
+	}
+	return variants
+}
+
+// NegotiateEncoding picks the content coding from available with the highest
+// quality in the Accept-Encoding header, preferring earlier entries of
+// available on ties. It returns "identity" if none of them is acceptable.
+func NegotiateEncoding(acceptEncoding string, available []string) string {
+	qualities := make(map[string]float64)
+	wildcard := -1.0
+	for _, entry := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(entry, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(name, "q") {
+				if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if coding == "*" {
+			wildcard = q
+		} else {
+			qualities[coding] = q
+		}
+	}
+	best, bestQ := "identity", 0.0
+	for _, coding := range available {
+		q, ok := qualities[coding]
+		if !ok {
+			q = max(wildcard, 0)
+		}
+		if q > bestQ {
+			best, bestQ = coding, q
+		}
+	}
+	return best
+}
+
+// writeEncoded compresses data on the fly if it is large enough and the client
+// accepts it. The caller is responsible for adding Vary: Accept-Encoding.
+func writeEncoded(resp http.ResponseWriter, req *http.Request, data []byte) {
+	header := resp.Header()
+	if len(data) >= compressionThreshold {
+		if encoding := NegotiateEncoding(req.Header.Get("Accept-Encoding"), precompressedEncodings); encoding != "identity" {
+			data = compress(encoding, data)
+			header.Set("Content-Encoding", encoding)
+		}
+	}
+	header.Set("Content-Length", strconv.Itoa(len(data)))
+	resp.Write(data)
+}
@@ -0,0 +1,62 @@
+package server_test
+
+import (
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	powergrim "github.com/phedny/powergrim-server/server"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	available := []string{"gzip", "deflate"}
+	for _, tc := range []struct {
+		acceptEncoding string
+		expected       string
+	}{
+		{"", "identity"},
+		{"gzip", "gzip"},
+		{"deflate, gzip", "gzip"},
+		{"gzip;q=0.5, deflate", "deflate"},
+		{"GZIP;Q=1", "gzip"},
+		{"gzip;q=0", "identity"},
+		{"br", "identity"},
+		{"*", "gzip"},
+		{"*;q=0.1, gzip;q=0", "deflate"},
+	} {
+		if got := powergrim.NegotiateEncoding(tc.acceptEncoding, available); got != tc.expected {
+			t.Errorf("NegotiateEncoding(%q) returned %q; expected %q", tc.acceptEncoding, got, tc.expected)
+		}
+	}
+}
+
+func TestDeflateIsZlib(t *testing.T) {
+	config := testConfig(t)
+	description := strings.Repeat("A town of villagers and a demon. ", 100)
+	writeTestFile(t, config.ScriptsDir, "long.json", `{"name": "Long", "scripts": [{"id": "long", "name": "Long", "tagline": "", "description": "`+description+`", "characters": []}]}`)
+	handler := newTestHandler(t, config)
+	gameId := createGame(t, handler, `{"script": "tb", "players": [], "reminders": []}`)
+	for range 20 {
+		serve(handler, "PATCH", "/game/"+gameId, powergrim.ActionContentType, `{"action": "advancePhase", "meta": {"note": "`+description[:100]+`"}}`)
+	}
+
+	for _, target := range []string{"/script/long", "/game/" + gameId + "/history"} {
+		req := httptest.NewRequest("GET", target, nil)
+		req.Header.Set("Accept-Encoding", "deflate")
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		if resp.Code != http.StatusOK || resp.Header().Get("Content-Encoding") != "deflate" {
+			t.Fatalf("GET %s returned %d with Content-Encoding %q", target, resp.Code, resp.Header().Get("Content-Encoding"))
+		}
+		r, err := zlib.NewReader(resp.Body)
+		if err != nil {
+			t.Fatalf("GET %s returned a body that is not zlib: %v", target, err)
+		}
+		if _, err := io.ReadAll(r); err != nil {
+			t.Fatalf("GET %s returned a body that is not zlib: %v", target, err)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ETag struct {
+	Weak   bool
+	Opaque string
+}
+
+func StrongETag(opaque string) ETag {
+	return ETag{Opaque: opaque}
+}
+
+func WeakETag(opaque string) ETag {
+	return ETag{Weak: true, Opaque: opaque}
+}
+
+func gameETag(version int) ETag {
+	return WeakETag(strconv.Itoa(version))
+}
+
+func (e ETag) String() string {
+	if e.Weak {
+		return `W/"` + e.Opaque + `"`
+	}
+	return `"` + e.Opaque + `"`
+}
+
+func (e ETag) StrongMatch(other ETag) bool {
+	return !e.Weak && !other.Weak && e.Opaque == other.Opaque
+}
+
+func (e ETag) WeakMatch(other ETag) bool {
+	return e.Opaque == other.Opaque
+}
+
+// ParseETagList parses the value of an If-Match or If-None-Match header.
+// Malformed entries are skipped, so that a bad entry never matches.
+func ParseETagList(value string) (etags []ETag, any bool) {
+	for {
+		value = strings.TrimLeft(value, " \t,")
+		if value == "" {
+			return etags, any
+		}
+		if value[0] == '*' {
+			any = true
+			value = value[1:]
+			continue
+		}
+		var etag ETag
+		if strings.HasPrefix(value, "W/") {
+			etag.Weak = true
+			value = value[2:]
+		}
+		if value == "" || value[0] != '"' {
+			value = skipListEntry(value)
+			continue
+		}
+		end := strings.IndexByte(value[1:], '"')
+		if end == -1 {
+			return etags, any
+		}
+		etag.Opaque = value[1 : end+1]
+		etags = append(etags, etag)
+		value = value[end+2:]
+	}
+}
+
+func skipListEntry(value string) string {
+	if comma := strings.IndexByte(value, ','); comma != -1 {
+		return value[comma+1:]
+	}
+	return ""
+}
+
+type Validators struct {
+	ETag         ETag
+	LastModified time.Time
+	// WeakIfMatch allows If-Match to use the weak comparison function. RFC
+	// 7232 requires strong comparison, but game versions identify the state
+	// exactly, so their weak ETags are safe to use for optimistic locking.
+	WeakIfMatch bool
+}
+
+func (v Validators) writeHeaders(header http.Header) {
+	header.Set("ETag", v.ETag.String())
+	if !v.LastModified.IsZero() {
+		header.Set("Last-Modified", v.LastModified.UTC().Format(http.TimeFormat))
+	}
+}
+
+func headerList(req *http.Request, name string) (string, bool) {
+	values := req.Header.Values(name)
+	return strings.Join(values, ","), len(values) != 0
+}
+
+func headerTime(req *http.Request, name string) (time.Time, bool) {
+	value := req.Header.Get(name)
+	if value == "" {
+		return time.Time{}, false
+	}
+	t, err := http.ParseTime(value)
+	return t, err == nil
+}
+
+// EvaluatePreconditions evaluates the conditional request headers against the
+// validators of an existing resource in the order of RFC 7232 section 6. It
+// returns 0 if the request should proceed, or the status code to respond with.
+func EvaluatePreconditions(req *http.Request, v Validators) int {
+	lastModified := v.LastModified.Truncate(time.Second)
+	if ifMatch, ok := headerList(req, "If-Match"); ok {
+		etags, any := ParseETagList(ifMatch)
+		if !any && !containsETag(etags, v.ETag, v.WeakIfMatch) {
+			return http.StatusPreconditionFailed
+		}
+	} else if t, ok := headerTime(req, "If-Unmodified-Since"); ok && !v.LastModified.IsZero() {
+		if lastModified.After(t) {
+			return http.StatusPreconditionFailed
+		}
+	}
+	safe := req.Method == http.MethodGet || req.Method == http.MethodHead
+	if ifNoneMatch, ok := headerList(req, "If-None-Match"); ok {
+		etags, any := ParseETagList(ifNoneMatch)
+		if any || containsETag(etags, v.ETag, true) {
+			if safe {
+				return http.StatusNotModified
+			}
+			return http.StatusPreconditionFailed
+		}
+	} else if t, ok := headerTime(req, "If-Modified-Since"); ok && safe && !v.LastModified.IsZero() {
+		if !lastModified.After(t) {
+			return http.StatusNotModified
+		}
+	}
+	return 0
+}
+
+func containsETag(etags []ETag, etag ETag, weak bool) bool {
+	for _, candidate := range etags {
+		if weak && candidate.WeakMatch(etag) || !weak && candidate.StrongMatch(etag) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPreconditions writes the validators to the response and, if a
+// precondition fails, the status code. It returns whether to proceed.
+func checkPreconditions(resp http.ResponseWriter, req *http.Request, v Validators) bool {
+	v.writeHeaders(resp.Header())
+	switch status := EvaluatePreconditions(req, v); status {
+	case 0:
+		return true
+	case http.StatusNotModified:
+		resp.WriteHeader(status)
+		return false
+	default:
+		writeProblem(resp, NewProblem(status, nil))
+		return false
+	}
+}
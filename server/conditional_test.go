@@ -0,0 +1,83 @@
+package server_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	powergrim "github.com/phedny/powergrim-server/server"
+)
+
+func TestParseETagList(t *testing.T) {
+	etags, any := powergrim.ParseETagList(`"a", W/"b",bogus, "c,d"`)
+	expected := []powergrim.ETag{
+		powergrim.StrongETag("a"),
+		powergrim.WeakETag("b"),
+		powergrim.StrongETag("c,d"),
+	}
+	if any || !reflect.DeepEqual(etags, expected) {
+		t.Fatalf("ParseETagList() returned (%#v, %t); expected (%#v, false)", etags, any, expected)
+	}
+}
+
+func TestParseETagListAny(t *testing.T) {
+	etags, any := powergrim.ParseETagList(`*`)
+	if !any || len(etags) != 0 {
+		t.Fatalf("ParseETagList() returned (%#v, %t); expected (nil, true)", etags, any)
+	}
+}
+
+func TestETagString(t *testing.T) {
+	if got := powergrim.WeakETag("1").String(); got != `W/"1"` {
+		t.Fatalf("String() returned %q; expected %q", got, `W/"1"`)
+	}
+	if got := powergrim.StrongETag("abc").String(); got != `"abc"` {
+		t.Fatalf("String() returned %q; expected %q", got, `"abc"`)
+	}
+}
+
+func TestEvaluatePreconditions(t *testing.T) {
+	lastModified := time.Date(2024, 3, 1, 12, 0, 0, 500, time.UTC)
+	strong := powergrim.Validators{ETag: powergrim.StrongETag("abc"), LastModified: lastModified}
+	weak := powergrim.Validators{ETag: powergrim.WeakETag("2"), LastModified: lastModified}
+	weakIfMatch := powergrim.Validators{ETag: powergrim.WeakETag("2"), LastModified: lastModified, WeakIfMatch: true}
+	before := lastModified.Add(-time.Hour).Format(http.TimeFormat)
+	after := lastModified.Format(http.TimeFormat)
+
+	for _, tc := range []struct {
+		name       string
+		method     string
+		headers    map[string]string
+		validators powergrim.Validators
+		expected   int
+	}{
+		{"unconditional", "GET", nil, strong, 0},
+		{"if-none-match list hit", "GET", map[string]string{"If-None-Match": `"x", "abc"`}, strong, http.StatusNotModified},
+		{"if-none-match miss", "GET", map[string]string{"If-None-Match": `"x"`}, strong, 0},
+		{"if-none-match weak comparison", "GET", map[string]string{"If-None-Match": `"2"`}, weak, http.StatusNotModified},
+		{"if-none-match any", "GET", map[string]string{"If-None-Match": `*`}, strong, http.StatusNotModified},
+		{"if-none-match on patch", "PATCH", map[string]string{"If-None-Match": `*`}, strong, http.StatusPreconditionFailed},
+		{"if-none-match takes precedence", "GET", map[string]string{"If-None-Match": `"x"`, "If-Modified-Since": after}, strong, 0},
+		{"if-modified-since not modified", "GET", map[string]string{"If-Modified-Since": after}, strong, http.StatusNotModified},
+		{"if-modified-since modified", "GET", map[string]string{"If-Modified-Since": before}, strong, 0},
+		{"if-modified-since invalid", "GET", map[string]string{"If-Modified-Since": "yesterday"}, strong, 0},
+		{"if-match strong hit", "PATCH", map[string]string{"If-Match": `"abc"`}, strong, 0},
+		{"if-match weak tag with strong comparison", "PATCH", map[string]string{"If-Match": `W/"2"`}, weak, http.StatusPreconditionFailed},
+		{"if-match weak tag with weak comparison", "PATCH", map[string]string{"If-Match": `W/"2"`}, weakIfMatch, 0},
+		{"if-match miss", "PATCH", map[string]string{"If-Match": `W/"1"`}, weakIfMatch, http.StatusPreconditionFailed},
+		{"if-match any", "PATCH", map[string]string{"If-Match": `*`}, strong, 0},
+		{"if-match takes precedence", "PATCH", map[string]string{"If-Match": `*`, "If-Unmodified-Since": before}, strong, 0},
+		{"if-unmodified-since modified", "PATCH", map[string]string{"If-Unmodified-Since": before}, strong, http.StatusPreconditionFailed},
+		{"if-unmodified-since unmodified", "PATCH", map[string]string{"If-Unmodified-Since": after}, strong, 0},
+	} {
+		req := httptest.NewRequest(tc.method, "/", nil)
+		for name, value := range tc.headers {
+			req.Header.Set(name, value)
+		}
+		if got := powergrim.EvaluatePreconditions(req, tc.validators); got != tc.expected {
+			t.Errorf("%s: EvaluatePreconditions() returned %d; expected %d", tc.name, got, tc.expected)
+		}
+	}
+}
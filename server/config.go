@@ -0,0 +1,375 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const envPrefix = "POWERGRIM_"
+
+var ErrInvalidConfig = errors.New("invalid configuration")
+
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+type Config struct {
+	Listen              string   `json:"listen"`
+	ScriptsDir          string   `json:"scriptsDir"`
+	LayoutsDir          string   `json:"layoutsDir"`
+	AllowedOrigins      []string `json:"allowedOrigins"`
+	Storage             string   `json:"storage"`
+	DataDir             string   `json:"dataDir,omitempty"`
+	MaxBodyBytes        int64    `json:"maxBodyBytes"`
+	MaxActionsPerBatch  int      `json:"maxActionsPerBatch"`
+	MaxPlayers          int      `json:"maxPlayers"`
+	MaxReminders        int      `json:"maxReminders"`
+	MaxHistory          int      `json:"maxHistory"`
+	ClientRateLimit     float64  `json:"clientRateLimit"`
+	ClientRateBurst     int      `json:"clientRateBurst"`
+	GameRateLimit       float64  `json:"gameRateLimit"`
+	GameRateBurst       int      `json:"gameRateBurst"`
+	ReadTimeout         Duration `json:"readTimeout"`
+	WriteTimeout        Duration `json:"writeTimeout"`
+	IdleTimeout         Duration `json:"idleTimeout"`
+	ShutdownTimeout     Duration `json:"shutdownTimeout"`
+	ReloadInterval      Duration `json:"reloadInterval"`
+	MaxWait             Duration `json:"maxWait"`
+	IdempotencyWindow   Duration `json:"idempotencyWindow"`
+	MaxIdempotencyKeys  int      `json:"maxIdempotencyKeys"`
+	WebhookAttempts     int      `json:"webhookAttempts"`
+	WebhookBackoff      Duration `json:"webhookBackoff"`
+	WebhookDisableAfter int      `json:"webhookDisableAfter"`
+	WebhookDenyNetworks []string `json:"webhookDenyNetworks"`
+	PublicUrl           string   `json:"publicUrl,omitempty"`
+	JoinUrl             string   `json:"joinUrl,omitempty"`
+	JoinCodeTtl         Duration `json:"joinCodeTtl"`
+	LogLevel            string   `json:"logLevel"`
+	LogFormat           string   `json:"logFormat"`
+}
+
+// privateNetworks are the loopback, link-local, private, multicast and
+// reserved networks, which webhooks may not connect to by default.
+var privateNetworks = []string{
+	"0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "127.0.0.0/8", "169.254.0.0/16", "172.16.0.0/12", "192.168.0.0/16",
+	"224.0.0.0/4", "240.0.0.0/4",
+	"::/128", "::1/128", "fc00::/7", "fe80::/10", "ff00::/8",
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Listen:              ":3000",
+		ScriptsDir:          "scripts",
+		LayoutsDir:          "layouts",
+		AllowedOrigins:      []string{}, // block cross-origin requests; * allows any origin
+		Storage:             "memory",
+		MaxBodyBytes:        1 << 20,
+		MaxActionsPerBatch:  100,
+		MaxPlayers:          30,
+		MaxReminders:        200,
+		MaxHistory:          1000,
+		ClientRateLimit:     5,
+		ClientRateBurst:     20,
+		GameRateLimit:       10,
+		GameRateBurst:       30,
+		ReadTimeout:         Duration(10 * time.Second),
+		WriteTimeout:        Duration(30 * time.Second),
+		IdleTimeout:         Duration(2 * time.Minute),
+		ShutdownTimeout:     Duration(15 * time.Second),
+		ReloadInterval:      Duration(2 * time.Second),
+		MaxWait:             Duration(time.Minute),
+		IdempotencyWindow:   Duration(time.Hour),
+		MaxIdempotencyKeys:  10000,
+		WebhookAttempts:     5,
+		WebhookBackoff:      Duration(time.Second),
+		WebhookDisableAfter: 3,
+		WebhookDenyNetworks: slices.Clone(privateNetworks),
+		JoinCodeTtl:         Duration(24 * time.Hour),
+		LogLevel:            "info",
+		LogFormat:           "text",
+	}
+}
+
+type configOption struct {
+	name  string
+	usage string
+	set   func(config *Config, value string) error
+}
+
+var configOptions = []configOption{
+	{"listen", "address to listen on", func(c *Config, v string) error { c.Listen = v; return nil }},
+	{"scripts-dir", "directory containing script files", func(c *Config, v string) error { c.ScriptsDir = v; return nil }},
+	{"layouts-dir", "directory containing layout files", func(c *Config, v string) error { c.LayoutsDir = v; return nil }},
+	{"allowed-origins", "comma-separated list of origins allowed to make cross-origin requests, or * to allow any origin (default none)", listOption(func(c *Config) *[]string { return &c.AllowedOrigins })},
+	{"storage", "storage backend for games: memory or file", func(c *Config, v string) error { c.Storage = v; return nil }},
+	{"data-dir", "directory to store games in when using file storage", func(c *Config, v string) error { c.DataDir = v; return nil }},
+	{"max-body-bytes", "maximum size of a request body", func(c *Config, v string) (err error) {
+		c.MaxBodyBytes, err = strconv.ParseInt(v, 10, 64)
+		return err
+	}},
+	{"max-actions-per-batch", "maximum number of actions in a single PATCH request, or 0 for no limit", intOption(func(c *Config) *int { return &c.MaxActionsPerBatch })},
+	{"max-players", "maximum number of players in a game, or 0 for no limit", intOption(func(c *Config) *int { return &c.MaxPlayers })},
+	{"max-reminders", "maximum number of reminders in a game, or 0 for no limit", intOption(func(c *Config) *int { return &c.MaxReminders })},
+	{"max-history", "maximum number of history entries kept per game, or 0 for no limit", intOption(func(c *Config) *int { return &c.MaxHistory })},
+	{"client-rate-limit", "sustained modifying requests per second per client IP, or 0 for no limit", floatOption(func(c *Config) *float64 { return &c.ClientRateLimit })},
+	{"client-rate-burst", "burst of modifying requests per client IP", intOption(func(c *Config) *int { return &c.ClientRateBurst })},
+	{"game-rate-limit", "sustained PATCH requests per second per game, or 0 for no limit", floatOption(func(c *Config) *float64 { return &c.GameRateLimit })},
+	{"game-rate-burst", "burst of PATCH requests per game", intOption(func(c *Config) *int { return &c.GameRateBurst })},
+	{"read-timeout", "maximum duration for reading a request", durationOption(func(c *Config) *Duration { return &c.ReadTimeout })},
+	{"write-timeout", "maximum duration for writing a response", durationOption(func(c *Config) *Duration { return &c.WriteTimeout })},
+	{"idle-timeout", "maximum duration to keep idle connections open", durationOption(func(c *Config) *Duration { return &c.IdleTimeout })},
+	{"shutdown-timeout", "maximum duration to drain requests when shutting down", durationOption(func(c *Config) *Duration { return &c.ShutdownTimeout })},
+	{"reload-interval", "interval for checking script and layout files for changes", durationOption(func(c *Config) *Duration { return &c.ReloadInterval })},
+	{"max-wait", "maximum duration a long-polling request waits for a game to change, 0 to disable", durationOption(func(c *Config) *Duration { return &c.MaxWait })},
+	{"idempotency-window", "duration to remember responses to requests with an Idempotency-Key", durationOption(func(c *Config) *Duration { return &c.IdempotencyWindow })},
+	{"max-idempotency-keys", "maximum number of responses to remember for requests with an Idempotency-Key", intOption(func(c *Config) *int { return &c.MaxIdempotencyKeys })},
+	{"webhook-attempts", "number of attempts to deliver a webhook event", intOption(func(c *Config) *int { return &c.WebhookAttempts })},
+	{"webhook-backoff", "delay before retrying a webhook delivery, doubled after every attempt", durationOption(func(c *Config) *Duration { return &c.WebhookBackoff })},
+	{"webhook-disable-after", "number of consecutive failed webhook deliveries after which a webhook is disabled", intOption(func(c *Config) *int { return &c.WebhookDisableAfter })},
+	{"webhook-deny-networks", "comma-separated list of networks in CIDR notation that webhooks may not connect to", listOption(func(c *Config) *[]string { return &c.WebhookDenyNetworks })},
+	{"public-url", "base URL at which clients reach this server, used for join links in QR codes", func(c *Config, v string) error { c.PublicUrl = v; return nil }},
+	{"join-url", "URL for joining a game encoded in QR codes, with {gameId} and {joinCode} replaced; defaults to the join code at the public URL", func(c *Config, v string) error { c.JoinUrl = v; return nil }},
+	{"join-code-ttl", "duration for which a join code is valid", durationOption(func(c *Config) *Duration { return &c.JoinCodeTtl })},
+	{"log-level", "minimum level of log messages: debug, info, warn or error", func(c *Config, v string) error { c.LogLevel = v; return nil }},
+	{"log-format", "format of log messages: text or json", func(c *Config, v string) error { c.LogFormat = v; return nil }},
+}
+
+func listOption(field func(c *Config) *[]string) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		*field(c) = nil
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*field(c) = append(*field(c), item)
+			}
+		}
+		return nil
+	}
+}
+
+func intOption(field func(c *Config) *int) func(c *Config, v string) error {
+	return func(c *Config, v string) (err error) {
+		*field(c), err = strconv.Atoi(v)
+		return err
+	}
+}
+
+func floatOption(field func(c *Config) *float64) func(c *Config, v string) error {
+	return func(c *Config, v string) (err error) {
+		*field(c), err = strconv.ParseFloat(v, 64)
+		return err
+	}
+}
+
+func durationOption(field func(c *Config) *Duration) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*field(c) = Duration(d)
+		return nil
+	}
+}
+
+func envName(optionName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(optionName, "-", "_"))
+}
+
+// LoadConfig builds the configuration from defaults, an optional JSON config
+// file, environment variables and command-line flags, in increasing order of
+// precedence.
+func LoadConfig(args []string, getenv func(string) string) (Config, error) {
+	flags := flag.NewFlagSet("powergrim-server", flag.ContinueOnError)
+	configFile := flags.String("config", getenv(envPrefix+"CONFIG"), "path to a JSON config file")
+	type flagValue struct {
+		option configOption
+		value  string
+	}
+	var flagValues []flagValue
+	for _, option := range configOptions {
+		flags.Func(option.name, fmt.Sprintf("%s (env %s)", option.usage, envName(option.name)), func(value string) error {
+			flagValues = append(flagValues, flagValue{option, value})
+			return nil
+		})
+	}
+	if err := flags.Parse(args); err != nil {
+		return Config{}, err
+	}
+	if flags.NArg() != 0 {
+		return Config{}, fmt.Errorf("%w: unexpected argument %q", ErrInvalidConfig, flags.Arg(0))
+	}
+
+	config := DefaultConfig()
+	if *configFile != "" {
+		data, err := os.ReadFile(*configFile)
+		if err != nil {
+			return Config{}, fmt.Errorf("failed to read config file: %w", err)
+		}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&config); err != nil && err != io.EOF {
+			return Config{}, fmt.Errorf("failed to parse config file %s: %w", *configFile, err)
+		}
+	}
+	for _, option := range configOptions {
+		if value := getenv(envName(option.name)); value != "" {
+			if err := option.set(&config, value); err != nil {
+				return Config{}, fmt.Errorf("%w: %s: %s", ErrInvalidConfig, envName(option.name), err)
+			}
+		}
+	}
+	for _, fv := range flagValues {
+		if err := fv.option.set(&config, fv.value); err != nil {
+			return Config{}, fmt.Errorf("%w: -%s: %s", ErrInvalidConfig, fv.option.name, err)
+		}
+	}
+	return config, config.Validate()
+}
+
+func (config Config) Validate() error {
+	if _, _, err := net.SplitHostPort(config.Listen); err != nil {
+		return fmt.Errorf("%w: listen: %s", ErrInvalidConfig, err)
+	}
+	if config.ScriptsDir == "" {
+		return fmt.Errorf("%w: scriptsDir must not be empty", ErrInvalidConfig)
+	}
+	if config.LayoutsDir == "" {
+		return fmt.Errorf("%w: layoutsDir must not be empty", ErrInvalidConfig)
+	}
+	for _, origin := range config.AllowedOrigins {
+		if origin == "*" {
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			return fmt.Errorf("%w: allowedOrigins: %q is not an origin", ErrInvalidConfig, origin)
+		}
+	}
+	switch config.Storage {
+	case "memory":
+	case "file":
+		if config.DataDir == "" {
+			return fmt.Errorf("%w: dataDir is required for file storage", ErrInvalidConfig)
+		}
+	default:
+		return fmt.Errorf("%w: storage must be memory or file", ErrInvalidConfig)
+	}
+	if config.MaxBodyBytes <= 0 {
+		return fmt.Errorf("%w: maxBodyBytes must be positive", ErrInvalidConfig)
+	}
+	if config.MaxActionsPerBatch < 0 || config.MaxPlayers < 0 || config.MaxReminders < 0 || config.MaxHistory < 0 {
+		return fmt.Errorf("%w: limits must not be negative", ErrInvalidConfig)
+	}
+	if config.ClientRateLimit < 0 || config.GameRateLimit < 0 {
+		return fmt.Errorf("%w: rate limits must not be negative", ErrInvalidConfig)
+	}
+	if (config.ClientRateLimit > 0 && config.ClientRateBurst < 1) || (config.GameRateLimit > 0 && config.GameRateBurst < 1) {
+		return fmt.Errorf("%w: rate limit bursts must be at least 1", ErrInvalidConfig)
+	}
+	if config.ReadTimeout < 0 || config.WriteTimeout < 0 || config.IdleTimeout < 0 || config.MaxWait < 0 {
+		return fmt.Errorf("%w: timeouts must not be negative", ErrInvalidConfig)
+	}
+	if config.ShutdownTimeout <= 0 {
+		return fmt.Errorf("%w: shutdownTimeout must be positive", ErrInvalidConfig)
+	}
+	if config.ReloadInterval <= 0 {
+		return fmt.Errorf("%w: reloadInterval must be positive", ErrInvalidConfig)
+	}
+	if config.IdempotencyWindow <= 0 {
+		return fmt.Errorf("%w: idempotencyWindow must be positive", ErrInvalidConfig)
+	}
+	if config.MaxIdempotencyKeys <= 0 {
+		return fmt.Errorf("%w: maxIdempotencyKeys must be positive", ErrInvalidConfig)
+	}
+	if config.WebhookAttempts < 1 || config.WebhookDisableAfter < 1 || config.WebhookBackoff < 0 {
+		return fmt.Errorf("%w: webhookAttempts and webhookDisableAfter must be positive and webhookBackoff must not be negative", ErrInvalidConfig)
+	}
+	if _, err := parseNetworks(config.WebhookDenyNetworks); err != nil {
+		return fmt.Errorf("%w: webhookDenyNetworks must be networks in CIDR notation", ErrInvalidConfig)
+	}
+	if config.JoinCodeTtl <= 0 {
+		return fmt.Errorf("%w: joinCodeTtl must be positive", ErrInvalidConfig)
+	}
+	if config.PublicUrl != "" {
+		if u, err := url.Parse(config.PublicUrl); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery != "" {
+			return fmt.Errorf("%w: publicUrl must be an absolute http or https URL without query", ErrInvalidConfig)
+		}
+	}
+	if config.JoinUrl != "" {
+		if u, err := url.Parse(config.JoinUrl); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%w: joinUrl must be an absolute http or https URL", ErrInvalidConfig)
+		}
+	}
+	if _, err := NewLogger(io.Discard, config.LogLevel, config.LogFormat); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (config Config) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("listen", config.Listen),
+		slog.String("scriptsDir", config.ScriptsDir),
+		slog.String("layoutsDir", config.LayoutsDir),
+		slog.Any("allowedOrigins", config.AllowedOrigins),
+		slog.String("storage", config.Storage),
+		slog.String("dataDir", config.DataDir),
+		slog.Int64("maxBodyBytes", config.MaxBodyBytes),
+		slog.Int("maxActionsPerBatch", config.MaxActionsPerBatch),
+		slog.Int("maxPlayers", config.MaxPlayers),
+		slog.Int("maxReminders", config.MaxReminders),
+		slog.Int("maxHistory", config.MaxHistory),
+		slog.Float64("clientRateLimit", config.ClientRateLimit),
+		slog.Int("clientRateBurst", config.ClientRateBurst),
+		slog.Float64("gameRateLimit", config.GameRateLimit),
+		slog.Int("gameRateBurst", config.GameRateBurst),
+		slog.Duration("readTimeout", time.Duration(config.ReadTimeout)),
+		slog.Duration("writeTimeout", time.Duration(config.WriteTimeout)),
+		slog.Duration("idleTimeout", time.Duration(config.IdleTimeout)),
+		slog.Duration("shutdownTimeout", time.Duration(config.ShutdownTimeout)),
+		slog.Duration("reloadInterval", time.Duration(config.ReloadInterval)),
+		slog.Duration("maxWait", time.Duration(config.MaxWait)),
+		slog.Duration("idempotencyWindow", time.Duration(config.IdempotencyWindow)),
+		slog.Int("maxIdempotencyKeys", config.MaxIdempotencyKeys),
+		slog.Int("webhookAttempts", config.WebhookAttempts),
+		slog.Duration("webhookBackoff", time.Duration(config.WebhookBackoff)),
+		slog.Int("webhookDisableAfter", config.WebhookDisableAfter),
+		slog.Any("webhookDenyNetworks", config.WebhookDenyNetworks),
+		slog.String("publicUrl", config.PublicUrl),
+		slog.String("joinUrl", config.JoinUrl),
+		slog.Duration("joinCodeTtl", time.Duration(config.JoinCodeTtl)),
+		slog.String("logLevel", config.LogLevel),
+		slog.String("logFormat", config.LogFormat),
+	)
+}
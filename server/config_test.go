@@ -0,0 +1,95 @@
+package server_test
+
+import (
+	"errors"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	powergrim "github.com/phedny/powergrim-server/server"
+)
+
+func TestLoadConfigDefaults(t *testing.T) {
+	got, err := powergrim.LoadConfig(nil, func(string) string { return "" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := powergrim.DefaultConfig()
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("LoadConfig() returned %#v; expected %#v", got, expected)
+	}
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(configFile, []byte(`{"listen":":4000","scriptsDir":"/srv/scripts","readTimeout":"5s"}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{
+		"POWERGRIM_CONFIG":          configFile,
+		"POWERGRIM_LISTEN":          ":5000",
+		"POWERGRIM_ALLOWED_ORIGINS": "https://a.example, https://b.example",
+	}
+	got, err := powergrim.LoadConfig([]string{"-listen", ":6000"}, func(name string) string { return env[name] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := powergrim.DefaultConfig()
+	expected.Listen = ":6000"
+	expected.ScriptsDir = "/srv/scripts"
+	expected.ReadTimeout = powergrim.Duration(5 * time.Second)
+	expected.AllowedOrigins = []string{"https://a.example", "https://b.example"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("LoadConfig() returned %#v; expected %#v", got, expected)
+	}
+}
+
+func TestLoadConfigAnyOrigin(t *testing.T) {
+	got, err := powergrim.LoadConfig([]string{"-allowed-origins", "*"}, func(string) string { return "" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.AllowedOrigins, []string{"*"}) {
+		t.Fatalf("LoadConfig() returned allowed origins %q; expected *", got.AllowedOrigins)
+	}
+}
+
+func TestDefaultWebhookDenyNetworks(t *testing.T) {
+	var prefixes []netip.Prefix
+	for _, network := range powergrim.DefaultConfig().WebhookDenyNetworks {
+		prefixes = append(prefixes, netip.MustParsePrefix(network))
+	}
+	for _, addr := range []string{"127.0.0.1", "192.168.1.1", "239.255.255.250", "255.255.255.255", "::1", "ff02::1"} {
+		if !slices.ContainsFunc(prefixes, func(prefix netip.Prefix) bool { return prefix.Contains(netip.MustParseAddr(addr)) }) {
+			t.Errorf("default webhook deny networks do not contain %s", addr)
+		}
+	}
+}
+
+func TestLoadConfigInvalid(t *testing.T) {
+	for _, args := range [][]string{
+		{"-listen", "3000"},
+		{"-storage", "file"},
+		{"-storage", "database"},
+		{"-max-body-bytes", "0"},
+		{"-max-history", "-1"},
+		{"-max-idempotency-keys", "0"},
+		{"-allowed-origins", "example.com"},
+		{"-read-timeout", "-1s"},
+		{"-shutdown-timeout", "0s"},
+		{"-webhook-deny-networks", "10.0.0.0"},
+		{"-public-url", "example.com"},
+		{"-log-level", "loud"},
+		{"-log-format", "xml"},
+	} {
+		_, err := powergrim.LoadConfig(args, func(string) string { return "" })
+		if !errors.Is(err, powergrim.ErrInvalidConfig) {
+			t.Errorf("LoadConfig(%q) returned error %v; expected %s", args, err, powergrim.ErrInvalidConfig)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const corsMaxAge = 10 * 60
+
+var corsAllowedMethods = []string{"GET", "HEAD", "POST", "PATCH", "DELETE"}
+
+var corsAllowedHeaders = []string{"Content-Type", "If-Match", "If-None-Match", "If-Modified-Since", "If-Unmodified-Since", "Prefer", "Idempotency-Key"}
+
+var corsExposedHeaders = []string{"ETag", "Last-Modified", "Location", "Preference-Applied", "Retry-After", "Idempotent-Replayed"}
+
+// CorsHandler answers CORS requests from allowedOrigins, which contains
+// origins or * to allow any origin. Requests from other origins get no CORS
+// headers, so browsers block them.
+func CorsHandler(allowedOrigins []string, handler http.Handler) http.Handler {
+	allowAny := slices.Contains(allowedOrigins, "*")
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		origin := req.Header.Get("Origin")
+		if origin == "" {
+			handler.ServeHTTP(resp, req)
+			return
+		}
+		header := resp.Header()
+		header.Add("Vary", "Origin")
+		allowed := allowAny || slices.Contains(allowedOrigins, origin)
+		if allowed {
+			if allowAny {
+				header.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				header.Set("Access-Control-Allow-Origin", origin)
+			}
+		}
+		if req.Method == http.MethodOptions && req.Header.Get("Access-Control-Request-Method") != "" {
+			if !allowed {
+				resp.WriteHeader(http.StatusForbidden)
+				return
+			}
+			method := req.Header.Get("Access-Control-Request-Method")
+			if !slices.Contains(corsAllowedMethods, method) {
+				resp.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			for _, requested := range strings.Split(req.Header.Get("Access-Control-Request-Headers"), ",") {
+				requested = strings.TrimSpace(requested)
+				if requested != "" && !slices.ContainsFunc(corsAllowedHeaders, func(h string) bool { return strings.EqualFold(h, requested) }) {
+					resp.WriteHeader(http.StatusForbidden)
+					return
+				}
+			}
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+			header.Set("Access-Control-Allow-Methods", strings.Join(corsAllowedMethods, ", "))
+			header.Set("Access-Control-Allow-Headers", strings.Join(corsAllowedHeaders, ", "))
+			header.Set("Access-Control-Max-Age", strconv.Itoa(corsMaxAge))
+			resp.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if allowed {
+			header.Set("Access-Control-Expose-Headers", strings.Join(corsExposedHeaders, ", "))
+		}
+		handler.ServeHTTP(resp, req)
+	})
+}
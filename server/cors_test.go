@@ -0,0 +1,94 @@
+package server_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	powergrim "github.com/phedny/powergrim-server/server"
+)
+
+var okHandler = http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+	resp.WriteHeader(http.StatusOK)
+})
+
+func TestCorsPreflight(t *testing.T) {
+	handler := powergrim.CorsHandler([]string{"https://grim.example"}, okHandler)
+	req := httptest.NewRequest(http.MethodOptions, "/game/abc", nil)
+	req.Header.Set("Origin", "https://grim.example")
+	req.Header.Set("Access-Control-Request-Method", "PATCH")
+	req.Header.Set("Access-Control-Request-Headers", "content-type, if-match")
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusNoContent {
+		t.Fatalf("preflight returned status %d; expected %d", resp.Code, http.StatusNoContent)
+	}
+	if got := resp.Header().Get("Access-Control-Allow-Origin"); got != "https://grim.example" {
+		t.Fatalf("preflight returned Access-Control-Allow-Origin %q; expected %q", got, "https://grim.example")
+	}
+	if got := resp.Header().Get("Access-Control-Allow-Methods"); got != "GET, HEAD, POST, PATCH, DELETE" {
+		t.Fatalf("preflight returned Access-Control-Allow-Methods %q", got)
+	}
+}
+
+func TestCorsPreflightDisallowedOrigin(t *testing.T) {
+	handler := powergrim.CorsHandler([]string{"https://grim.example"}, okHandler)
+	req := httptest.NewRequest(http.MethodOptions, "/game/abc", nil)
+	req.Header.Set("Origin", "https://evil.example")
+	req.Header.Set("Access-Control-Request-Method", "PATCH")
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusForbidden {
+		t.Fatalf("preflight returned status %d; expected %d", resp.Code, http.StatusForbidden)
+	}
+	if got := resp.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Fatalf("preflight returned Access-Control-Allow-Origin %q; expected none", got)
+	}
+}
+
+func TestCorsPreflightDisallowedHeader(t *testing.T) {
+	handler := powergrim.CorsHandler([]string{"*"}, okHandler)
+	req := httptest.NewRequest(http.MethodOptions, "/game/abc", nil)
+	req.Header.Set("Origin", "https://grim.example")
+	req.Header.Set("Access-Control-Request-Method", "PATCH")
+	req.Header.Set("Access-Control-Request-Headers", "X-Secret")
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusForbidden {
+		t.Fatalf("preflight returned status %d; expected %d", resp.Code, http.StatusForbidden)
+	}
+}
+
+func TestCorsSimpleRequest(t *testing.T) {
+	handler := powergrim.CorsHandler([]string{"*"}, okHandler)
+	req := httptest.NewRequest(http.MethodGet, "/game/abc", nil)
+	req.Header.Set("Origin", "https://grim.example")
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusOK {
+		t.Fatalf("request returned status %d; expected %d", resp.Code, http.StatusOK)
+	}
+	if got := resp.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Fatalf("request returned Access-Control-Allow-Origin %q; expected %q", got, "*")
+	}
+	if got := resp.Header().Get("Access-Control-Expose-Headers"); got != "ETag, Last-Modified, Location, Preference-Applied, Retry-After, Idempotent-Replayed" {
+		t.Fatalf("request returned Access-Control-Expose-Headers %q", got)
+	}
+}
+
+func TestCorsDefaultBlocksOrigins(t *testing.T) {
+	handler := powergrim.CorsHandler(powergrim.DefaultConfig().AllowedOrigins, okHandler)
+	req := httptest.NewRequest(http.MethodOptions, "/game/abc", nil)
+	req.Header.Set("Origin", "https://grim.example")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusForbidden || resp.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("preflight with the default config returned status %d and Access-Control-Allow-Origin %q", resp.Code, resp.Header().Get("Access-Control-Allow-Origin"))
+	}
+}
@@ -0,0 +1,332 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type VersionedFile struct {
+	lastModified time.Time
+	hash         string
+	data         []byte
+	encoded      map[string][]byte
+}
+
+type fileSet[T any] struct {
+	dirName     string
+	urlPath     string
+	contentType string
+	filesLoaded func(decoded map[string]T) error
+	mut         sync.RWMutex
+	files       map[string]VersionedFile
+	decoded     map[string]T
+	failed      map[string]time.Time
+	// rejected holds the modification times of the files when filesLoaded
+	// last rejected them, so that they are not reloaded until one changes.
+	rejected map[string]time.Time
+}
+
+func handleFiles[T any](mux *http.ServeMux, dirName, urlPath, contentType string, filesLoaded func(decoded map[string]T) error) (*fileSet[T], error) {
+	fs := &fileSet[T]{
+		dirName:     dirName,
+		urlPath:     urlPath,
+		contentType: contentType,
+		filesLoaded: filesLoaded,
+		files:       make(map[string]VersionedFile),
+		decoded:     make(map[string]T),
+		failed:      make(map[string]time.Time),
+	}
+	infos, err := fs.scan()
+	if err != nil {
+		return nil, err
+	}
+	for fileName, info := range infos {
+		file, decoded, err := fs.load(info)
+		if err != nil {
+			return nil, err
+		}
+		fs.files[fileName] = file
+		fs.decoded[fileName] = decoded
+	}
+	if filesLoaded != nil {
+		if err := filesLoaded(fs.decoded); err != nil {
+			return nil, err
+		}
+	}
+	mux.HandleFunc(fmt.Sprintf("/%s/{id}", urlPath), fs.serveFile)
+	return fs, nil
+}
+
+func (fs *fileSet[T]) scan() (map[string]os.FileInfo, error) {
+	entries, err := os.ReadDir(fs.dirName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s directory: %w", fs.dirName, err)
+	}
+	infos := make(map[string]os.FileInfo)
+	for _, entry := range entries {
+		if filepath.Ext(entry.Name()) != ".json" || !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read info on %s/%s file: %w", fs.dirName, entry.Name(), err)
+		}
+		infos[entry.Name()[:len(entry.Name())-5]] = info
+	}
+	return infos, nil
+}
+
+func (fs *fileSet[T]) load(info os.FileInfo) (VersionedFile, T, error) {
+	var decoded T
+	data, err := os.ReadFile(path.Join(fs.dirName, info.Name()))
+	if err != nil {
+		return VersionedFile{}, decoded, fmt.Errorf("failed to read %s/%s file: %w", fs.dirName, info.Name(), err)
+	}
+	decoded, err = decodeFile[T](data)
+	if err != nil {
+		return VersionedFile{}, decoded, fmt.Errorf("failed to parse %s/%s file: %w", fs.dirName, info.Name(), err)
+	}
+	return newVersionedFile(info.ModTime(), data), decoded, nil
+}
+
+// watch reloads the files when the directory changes, until ctx is done.
+func (fs *fileSet[T]) watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		infos, err := fs.scan()
+		if err != nil {
+			slog.Error("failed to scan directory", "dir", fs.dirName, "error", err)
+			continue
+		}
+		fs.mut.RLock()
+		changed := fs.changed(infos)
+		fs.mut.RUnlock()
+		if changed {
+			fs.reload()
+		}
+	}
+}
+
+func (fs *fileSet[T]) changed(infos map[string]os.FileInfo) bool {
+	if fs.rejected != nil && maps.EqualFunc(fs.rejected, infos, func(modTime time.Time, info os.FileInfo) bool { return modTime.Equal(info.ModTime()) }) {
+		return false
+	}
+	for fileName := range fs.files {
+		if _, ok := infos[fileName]; !ok {
+			return true
+		}
+	}
+	for fileName := range fs.failed {
+		if _, ok := infos[fileName]; !ok {
+			return true
+		}
+	}
+	for fileName, info := range infos {
+		file, ok := fs.files[fileName]
+		if ok && file.lastModified.Equal(info.ModTime()) {
+			continue
+		}
+		failedModTime, ok := fs.failed[fileName]
+		if ok && failedModTime.Equal(info.ModTime()) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+func (fs *fileSet[T]) reload() {
+	fs.mut.Lock()
+	defer fs.mut.Unlock()
+	infos, err := fs.scan()
+	if err != nil {
+		slog.Error("failed to scan directory", "dir", fs.dirName, "error", err)
+		return
+	}
+	files := make(map[string]VersionedFile)
+	decodedFiles := make(map[string]T)
+	failed := make(map[string]time.Time)
+	for fileName, info := range infos {
+		if file, ok := fs.files[fileName]; ok && file.lastModified.Equal(info.ModTime()) {
+			files[fileName] = file
+			decodedFiles[fileName] = fs.decoded[fileName]
+			continue
+		}
+		if failedModTime, ok := fs.failed[fileName]; ok && failedModTime.Equal(info.ModTime()) {
+			failed[fileName] = failedModTime
+			if file, ok := fs.files[fileName]; ok {
+				files[fileName] = file
+				decodedFiles[fileName] = fs.decoded[fileName]
+			}
+			continue
+		}
+		file, decoded, err := fs.load(info)
+		if err != nil {
+			slog.Error("failed to load file, keeping previous version if any", "dir", fs.dirName, "file", info.Name(), "error", err)
+			failed[fileName] = info.ModTime()
+			if file, ok := fs.files[fileName]; ok {
+				files[fileName] = file
+				decodedFiles[fileName] = fs.decoded[fileName]
+			}
+			continue
+		}
+		files[fileName] = file
+		decodedFiles[fileName] = decoded
+	}
+	if fs.filesLoaded != nil {
+		if err := fs.filesLoaded(decodedFiles); err != nil {
+			slog.Error("failed to reload directory, keeping previous version", "dir", fs.dirName, "error", err)
+			fs.rejected = make(map[string]time.Time, len(infos))
+			for fileName, info := range infos {
+				fs.rejected[fileName] = info.ModTime()
+			}
+			return
+		}
+	}
+	fs.files = files
+	fs.decoded = decodedFiles
+	fs.failed = failed
+	fs.rejected = nil
+	slog.Info("reloaded directory", "dir", fs.dirName, "files", len(files))
+}
+
+func (fs *fileSet[T]) get(fileName string) (T, bool) {
+	fs.mut.RLock()
+	defer fs.mut.RUnlock()
+	decoded, ok := fs.decoded[fileName]
+	return decoded, ok
+}
+
+// getFile is like get, but also returns the file that was decoded.
+func (fs *fileSet[T]) getFile(fileName string) (T, VersionedFile, bool) {
+	fs.mut.RLock()
+	defer fs.mut.RUnlock()
+	decoded, ok := fs.decoded[fileName]
+	return decoded, fs.files[fileName], ok
+}
+
+func decodeFile[T any](data []byte) (T, error) {
+	var decoded T
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&decoded)
+	return decoded, err
+}
+
+func newVersionedFile(lastModified time.Time, data []byte) VersionedFile {
+	hash := sha256.Sum256(data)
+	return VersionedFile{
+		lastModified: lastModified,
+		hash:         hex.EncodeToString(hash[:]),
+		data:         data,
+		encoded:      precompress(data),
+	}
+}
+
+func (fs *fileSet[T]) serveFile(resp http.ResponseWriter, req *http.Request) {
+	fs.mut.RLock()
+	file, ok := fs.files[req.PathValue("id")]
+	fs.mut.RUnlock()
+	if !ok {
+		writeProblem(resp, NewProblem(http.StatusNotFound, nil))
+		return
+	}
+	available := make([]string, 0, len(file.encoded))
+	for _, encoding := range precompressedEncodings {
+		if _, ok := file.encoded[encoding]; ok {
+			available = append(available, encoding)
+		}
+	}
+	encoding := NegotiateEncoding(req.Header.Get("Accept-Encoding"), available)
+	validators := Validators{
+		ETag:         StrongETag(file.hash),
+		LastModified: file.lastModified,
+	}
+	data := file.data
+	if encoding != "identity" {
+		validators.ETag = StrongETag(file.hash + "-" + encoding)
+		data = file.encoded[encoding]
+	}
+	header := resp.Header()
+	header.Add("Vary", "Accept-Encoding")
+	if !checkPreconditions(resp, req, validators) {
+		return
+	}
+	header.Add("Content-Type", fs.contentType)
+	if encoding != "identity" {
+		header.Set("Content-Encoding", encoding)
+	}
+	header.Set("Content-Length", strconv.Itoa(len(data)))
+	resp.Write(data)
+}
+
+func (fs *fileSet[T]) uploadFile(resp http.ResponseWriter, req *http.Request) {
+	header := resp.Header()
+	contentType := req.Header["Content-Type"]
+	if len(contentType) != 1 || contentType[0] != fs.contentType {
+		writeProblem(resp, NewProblem(http.StatusUnsupportedMediaType, nil))
+		return
+	}
+	data, err := io.ReadAll(req.Body)
+	if err != nil {
+		decodeFailed(resp, err)
+		return
+	}
+	decoded, err := decodeFile[T](data)
+	if err != nil {
+		writeProblem(resp, NewProblem(http.StatusBadRequest, err))
+		return
+	}
+	fileName := uuid.NewString()
+	filePath := path.Join(fs.dirName, fileName+".json")
+
+	fs.mut.Lock()
+	defer fs.mut.Unlock()
+	if err := os.WriteFile(filePath, data, 0o644); err != nil {
+		writeProblem(resp, NewProblem(http.StatusInternalServerError, nil))
+		return
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		os.Remove(filePath)
+		writeProblem(resp, NewProblem(http.StatusInternalServerError, nil))
+		return
+	}
+	decodedFiles := maps.Clone(fs.decoded)
+	decodedFiles[fileName] = decoded
+	if fs.filesLoaded != nil {
+		if err := fs.filesLoaded(decodedFiles); err != nil {
+			os.Remove(filePath)
+			writeProblem(resp, NewProblem(http.StatusConflict, err))
+			return
+		}
+	}
+	files := maps.Clone(fs.files)
+	files[fileName] = newVersionedFile(info.ModTime(), data)
+	fs.files = files
+	fs.decoded = decodedFiles
+	header.Add("Location", fmt.Sprintf("/%s/%s", fs.urlPath, fileName))
+	resp.WriteHeader(http.StatusCreated)
+}
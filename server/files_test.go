@@ -0,0 +1,164 @@
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	powergrim "github.com/phedny/powergrim-server/server"
+)
+
+const testScriptFile = `{"name": "Trouble Brewing", "scripts": [{"id": "tb", "name": "Trouble Brewing", "tagline": "", "description": "", "characters": []}]}`
+
+func findScriptFile(t *testing.T, handler http.Handler, scriptId string) string {
+	resp := serve(handler, "GET", "/findScript?q="+scriptId, "", "")
+	var scriptFileId *string
+	if err := json.NewDecoder(resp.Body).Decode(&scriptFileId); err != nil {
+		t.Fatal(err)
+	}
+	if scriptFileId == nil {
+		return ""
+	}
+	return *scriptFileId
+}
+
+func TestUploadFile(t *testing.T) {
+	config := testConfig(t)
+	handler := newTestHandler(t, config)
+
+	resp := serve(handler, "POST", "/script", powergrim.ScriptfileContentType, testScriptFile)
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("POST /script returned %d %s", resp.Code, resp.Body)
+	}
+	location := resp.Header().Get("Location")
+	scriptFileId := strings.TrimPrefix(location, "/script/")
+	if data, err := os.ReadFile(path.Join(config.ScriptsDir, scriptFileId+".json")); err != nil || string(data) != testScriptFile {
+		t.Fatalf("uploaded file was stored as %q, %v", data, err)
+	}
+	if resp := serve(handler, "GET", location, "", ""); resp.Code != http.StatusOK || resp.Body.String() != testScriptFile {
+		t.Fatalf("GET %s returned %d %s", location, resp.Code, resp.Body)
+	}
+	for _, scriptId := range []string{"tb", "TB"} {
+		if got := findScriptFile(t, handler, scriptId); got != scriptFileId {
+			t.Fatalf("findScript(%q) returned %q; expected %q", scriptId, got, scriptFileId)
+		}
+	}
+}
+
+func TestUploadFileInvalid(t *testing.T) {
+	config := testConfig(t)
+	handler := newTestHandler(t, config)
+	if resp := serve(handler, "POST", "/script", powergrim.ScriptfileContentType, testScriptFile); resp.Code != http.StatusCreated {
+		t.Fatalf("POST /script returned %d %s", resp.Code, resp.Body)
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+	}{
+		{"wrong content type", powergrim.JsonContentType, testScriptFile, http.StatusUnsupportedMediaType},
+		{"malformed JSON", powergrim.ScriptfileContentType, `{"name": `, http.StatusBadRequest},
+		{"unknown field", powergrim.ScriptfileContentType, `{"name": "Other", "scripts": [], "editions": []}`, http.StatusBadRequest},
+		{"duplicate script id", powergrim.ScriptfileContentType, testScriptFile, http.StatusConflict},
+	}
+	for _, test := range tests {
+		if resp := serve(handler, "POST", "/script", test.contentType, test.body); resp.Code != test.status {
+			t.Errorf("POST /script with %s returned %d; expected %d", test.name, resp.Code, test.status)
+		}
+	}
+	if entries, _ := os.ReadDir(config.ScriptsDir); len(entries) != 1 {
+		t.Fatalf("scripts directory has %d files; expected 1", len(entries))
+	}
+}
+
+// rewriteTestFile writes a file with a modification time that differs from
+// the previous version, so that the change is noticed by the watcher.
+func rewriteTestFile(t *testing.T, dirName, fileName, content string, modTime time.Time) {
+	writeTestFile(t, dirName, fileName, content)
+	if err := os.Chtimes(path.Join(dirName, fileName), modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func waitForBody(t *testing.T, handler http.Handler, target, expected string) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp := serve(handler, "GET", target, "", "")
+		if resp.Code == http.StatusOK && resp.Body.String() == expected {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("GET %s returned %d %s; expected %s", target, resp.Code, resp.Body, expected)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestHotReload(t *testing.T) {
+	const (
+		circle = `{"name": "Circle", "dimensions": [400, 400], "backgroundUrl": "", "newPlayerToken": [0, 0]}`
+		square = `{"name": "Square", "dimensions": [400, 400], "backgroundUrl": "", "newPlayerToken": [0, 0]}`
+	)
+	config := testConfig(t)
+	config.ReloadInterval = powergrim.Duration(10 * time.Millisecond)
+	modTime := time.Now().Add(-time.Hour)
+	rewriteTestFile(t, config.LayoutsDir, "table.json", circle, modTime)
+	handler := newTestHandler(t, config)
+
+	rewriteTestFile(t, config.LayoutsDir, "table.json", square, modTime.Add(time.Second))
+	waitForBody(t, handler, "/layout/table", square)
+
+	rewriteTestFile(t, config.LayoutsDir, "table.json", `{"name": `, modTime.Add(2*time.Second))
+	time.Sleep(100 * time.Millisecond)
+	waitForBody(t, handler, "/layout/table", square)
+
+	rewriteTestFile(t, config.LayoutsDir, "table.json", circle, modTime.Add(3*time.Second))
+	waitForBody(t, handler, "/layout/table", circle)
+	os.Remove(path.Join(config.LayoutsDir, "table.json"))
+	deadline := time.Now().Add(5 * time.Second)
+	for serve(handler, "GET", "/layout/table", "", "").Code != http.StatusNotFound {
+		if time.Now().After(deadline) {
+			t.Fatalf("removed layout is still served")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestHotReloadSwapsAllFiles(t *testing.T) {
+	const (
+		first  = `{"name": "First", "scripts": [{"id": "a", "name": "A", "tagline": "", "description": "", "characters": []}]}`
+		second = `{"name": "Second", "scripts": [{"id": "b", "name": "B", "tagline": "", "description": "", "characters": []}]}`
+		third  = `{"name": "Third", "scripts": [{"id": "c", "name": "C", "tagline": "", "description": "", "characters": []}]}`
+	)
+	config := testConfig(t)
+	config.ReloadInterval = powergrim.Duration(10 * time.Millisecond)
+	modTime := time.Now().Add(-time.Hour)
+	rewriteTestFile(t, config.ScriptsDir, "first.json", first, modTime)
+	rewriteTestFile(t, config.ScriptsDir, "second.json", second, modTime)
+	handler := newTestHandler(t, config)
+
+	// A new file and a changed file that reuses a script id are rejected
+	// together, keeping all files at their previous version.
+	rewriteTestFile(t, config.ScriptsDir, "third.json", third, modTime.Add(time.Second))
+	rewriteTestFile(t, config.ScriptsDir, "second.json", first, modTime.Add(time.Second))
+	time.Sleep(100 * time.Millisecond)
+	waitForBody(t, handler, "/script/second", second)
+	if resp := serve(handler, "GET", "/script/third", "", ""); resp.Code != http.StatusNotFound {
+		t.Fatalf("GET /script/third returned %d; expected 404", resp.Code)
+	}
+	if got := findScriptFile(t, handler, "b"); got != "second" {
+		t.Fatalf("findScript returned %q; expected second", got)
+	}
+
+	rewriteTestFile(t, config.ScriptsDir, "second.json", second, modTime.Add(2*time.Second))
+	waitForBody(t, handler, "/script/third", third)
+	if got := findScriptFile(t, handler, "c"); got != "third" {
+		t.Fatalf("findScript returned %q; expected third", got)
+	}
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"sync"
+)
+
+type gameEntry struct {
+	mut     sync.Mutex
+	game    VersionedGame
+	changed chan struct{}
+}
+
+// GameRegistry holds the games. Each game has its own lock, so that updates
+// to a game are serialised without holding up requests for other games.
+type GameRegistry struct {
+	mut     sync.RWMutex
+	entries map[string]*gameEntry
+	save    func(gameId string, game VersionedGame)
+}
+
+// NewGameRegistry returns a registry holding games. If save is not nil, it is
+// called with every added or updated game while holding the lock of the game.
+func NewGameRegistry(games map[string]VersionedGame, save func(gameId string, game VersionedGame)) *GameRegistry {
+	entries := make(map[string]*gameEntry, len(games))
+	for gameId, game := range games {
+		entries[gameId] = &gameEntry{game: game}
+	}
+	return &GameRegistry{entries: entries, save: save}
+}
+
+func (r *GameRegistry) entry(gameId string) (*gameEntry, bool) {
+	r.mut.RLock()
+	entry, ok := r.entries[gameId]
+	r.mut.RUnlock()
+	return entry, ok
+}
+
+func (r *GameRegistry) Len() int {
+	r.mut.RLock()
+	defer r.mut.RUnlock()
+	return len(r.entries)
+}
+
+func (r *GameRegistry) Add(gameId string, game VersionedGame) {
+	entry := &gameEntry{game: game}
+	entry.mut.Lock()
+	defer entry.mut.Unlock()
+	r.mut.Lock()
+	r.entries[gameId] = entry
+	r.mut.Unlock()
+	if r.save != nil {
+		r.save(gameId, game)
+	}
+}
+
+func (r *GameRegistry) Get(gameId string) (VersionedGame, bool) {
+	entry, ok := r.entry(gameId)
+	if !ok {
+		return VersionedGame{}, false
+	}
+	entry.mut.Lock()
+	defer entry.mut.Unlock()
+	return entry.game, true
+}
+
+// Watch returns the game together with a channel that is closed when the game
+// is next updated.
+func (r *GameRegistry) Watch(gameId string) (VersionedGame, <-chan struct{}, bool) {
+	entry, ok := r.entry(gameId)
+	if !ok {
+		return VersionedGame{}, nil, false
+	}
+	entry.mut.Lock()
+	defer entry.mut.Unlock()
+	if entry.changed == nil {
+		entry.changed = make(chan struct{})
+	}
+	return entry.game, entry.changed, true
+}
+
+// Update calls update with the current state of the game while holding its
+// lock and stores the returned game, unless update returns an error. It
+// returns the stored game, whether the game exists and the error of update.
+// Watchers are only woken when the version of the game changes.
+func (r *GameRegistry) Update(gameId string, update func(game VersionedGame) (VersionedGame, error)) (VersionedGame, bool, error) {
+	entry, ok := r.entry(gameId)
+	if !ok {
+		return VersionedGame{}, false, nil
+	}
+	entry.mut.Lock()
+	defer entry.mut.Unlock()
+	game, err := update(entry.game)
+	if err != nil {
+		return entry.game, true, err
+	}
+	previous := entry.game
+	entry.game = game
+	if r.save != nil {
+		r.save(gameId, game)
+	}
+	if entry.changed != nil && game.Version != previous.Version {
+		close(entry.changed)
+		entry.changed = nil
+	}
+	return game, true, nil
+}
+
+// Range calls f for every game, holding the lock of one game at a time.
+func (r *GameRegistry) Range(f func(gameId string, game VersionedGame)) {
+	r.mut.RLock()
+	entries := make(map[string]*gameEntry, len(r.entries))
+	for gameId, entry := range r.entries {
+		entries[gameId] = entry
+	}
+	r.mut.RUnlock()
+	for gameId, entry := range entries {
+		entry.mut.Lock()
+		f(gameId, entry.game)
+		entry.mut.Unlock()
+	}
+}
@@ -0,0 +1,106 @@
+package server_test
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/phedny/powergrim-server/grimoire"
+	powergrim "github.com/phedny/powergrim-server/server"
+)
+
+func incrementVersion(game powergrim.VersionedGame) (powergrim.VersionedGame, error) {
+	game.Version++
+	return game, nil
+}
+
+func TestGameRegistryUpdateSerialises(t *testing.T) {
+	registry := powergrim.NewGameRegistry(map[string]powergrim.VersionedGame{"a": {Version: 1}}, nil)
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			registry.Update("a", incrementVersion)
+		}()
+	}
+	wg.Wait()
+	if game, _ := registry.Get("a"); game.Version != 101 {
+		t.Fatalf("Get() returned version %d; expected 101", game.Version)
+	}
+	if _, ok, _ := registry.Update("b", incrementVersion); ok {
+		t.Fatalf("Update() found a game that does not exist")
+	}
+}
+
+func TestGameRegistryWatch(t *testing.T) {
+	var saved []int
+	registry := powergrim.NewGameRegistry(nil, func(gameId string, game powergrim.VersionedGame) {
+		saved = append(saved, game.Version)
+	})
+	registry.Add("a", powergrim.VersionedGame{Version: 1})
+	_, changed, ok := registry.Watch("a")
+	if !ok {
+		t.Fatalf("Watch() did not find the game")
+	}
+	registry.Update("a", func(game powergrim.VersionedGame) (powergrim.VersionedGame, error) {
+		return game, fmt.Errorf("failed")
+	})
+	select {
+	case <-changed:
+		t.Fatalf("failed Update() closed the changed channel")
+	default:
+	}
+	registry.Update("a", func(game powergrim.VersionedGame) (powergrim.VersionedGame, error) {
+		game.JoinCode = powergrim.JoinCode{Code: "ABCDEF"}
+		return game, nil
+	})
+	select {
+	case <-changed:
+		t.Fatalf("Update() without a new version closed the changed channel")
+	default:
+	}
+	registry.Update("a", incrementVersion)
+	select {
+	case <-changed:
+	default:
+		t.Fatalf("Update() did not close the changed channel")
+	}
+	if len(saved) != 3 || saved[0] != 1 || saved[1] != 1 || saved[2] != 2 {
+		t.Fatalf("registry saved versions %v; expected [1 1 2]", saved)
+	}
+}
+
+func BenchmarkConcurrentPatch(b *testing.B) {
+	const gameCount = 200
+	initial := make(map[string]powergrim.VersionedGame, gameCount)
+	for i := 0; i < gameCount; i++ {
+		var game grimoire.Game
+		for id := 1; id <= 10; id++ {
+			game.Players = append(game.Players, grimoire.Player{Id: id, Alive: true})
+		}
+		initial[fmt.Sprint(i)] = powergrim.VersionedGame{Version: 1, Game: game}
+	}
+	registry := powergrim.NewGameRegistry(initial, nil)
+	var next atomic.Int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			n := next.Add(1)
+			action := grimoire.UpdatePlayer{Id: int(n%10) + 1, Character: fmt.Sprint("character", n%3)}
+			_, _, err := registry.Update(fmt.Sprint(n%gameCount), func(game powergrim.VersionedGame) (powergrim.VersionedGame, error) {
+				newGame, err := game.Game.ApplyAction(action)
+				if err != nil {
+					return game, err
+				}
+				game.Game = newGame
+				game.Version++
+				return game, nil
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/phedny/powergrim-server/grimoire"
+)
+
+// HistoryEntry records an action applied to a game, together with the
+// version it resulted in and the metadata it was sent with.
+type HistoryEntry struct {
+	Version    int             `json:"version"`
+	AppliedAt  time.Time       `json:"appliedAt"`
+	Author     string          `json:"author,omitempty"`
+	ClientTime *time.Time      `json:"clientTime,omitempty"`
+	Note       string          `json:"note,omitempty"`
+	Action     json.RawMessage `json:"action"`
+}
+
+func historyEntries(version int, appliedAt time.Time, actions []grimoire.WrappedAction) ([]HistoryEntry, error) {
+	entries := make([]HistoryEntry, len(actions))
+	for i, action := range actions {
+		data, err := json.Marshal(action.Action)
+		if err != nil {
+			return nil, err
+		}
+		entries[i] = HistoryEntry{
+			Version:    version,
+			AppliedAt:  appliedAt,
+			Author:     action.Meta.Author,
+			ClientTime: action.Meta.ClientTime,
+			Note:       action.Meta.Note,
+			Action:     data,
+		}
+	}
+	return entries, nil
+}
+
+// appendHistory appends entries to the history of a game, dropping the oldest
+// entries when it grows beyond the configured maximum.
+func appendHistory(history, entries []HistoryEntry) []HistoryEntry {
+	history = append(slices.Clip(history), entries...)
+	if config.MaxHistory > 0 && len(history) > config.MaxHistory {
+		history = slices.Clone(history[len(history)-config.MaxHistory:])
+	}
+	return history
+}
+
+func gameHistory(resp http.ResponseWriter, req *http.Request) {
+	game, ok := games.Get(req.PathValue("gameId"))
+	if !ok {
+		writeProblem(resp, NewProblem(http.StatusNotFound, nil))
+		return
+	}
+	since := 0
+	if req.URL.Query().Has("since") {
+		var err error
+		since, err = strconv.Atoi(req.URL.Query().Get("since"))
+		if err != nil {
+			writeProblem(resp, NewProblem(http.StatusBadRequest, nil))
+			return
+		}
+	}
+	resp.Header().Add("Vary", "Accept-Encoding")
+	if !checkPreconditions(resp, req, game.validators()) {
+		return
+	}
+	entries := []HistoryEntry{}
+	for _, entry := range game.History {
+		if entry.Version > since {
+			entries = append(entries, entry)
+		}
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		writeProblem(resp, NewProblem(http.StatusInternalServerError, err))
+		return
+	}
+	resp.Header().Add("Content-Type", JsonContentType)
+	writeEncoded(resp, req, append(data, '\n'))
+}
@@ -0,0 +1,51 @@
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	powergrim "github.com/phedny/powergrim-server/server"
+)
+
+func historyVersions(t *testing.T, handler http.Handler, target string) []int {
+	resp := serve(handler, "GET", target, "", "")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("GET %s returned %d %s", target, resp.Code, resp.Body)
+	}
+	var entries []powergrim.HistoryEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		t.Fatal(err)
+	}
+	versions := make([]int, len(entries))
+	for i, entry := range entries {
+		versions[i] = entry.Version
+	}
+	return versions
+}
+
+func TestGameHistory(t *testing.T) {
+	config := testConfig(t)
+	config.MaxHistory = 3
+	handler := newTestHandler(t, config)
+	gameId := createGame(t, handler, `{"script": "tb", "players": [], "reminders": []}`)
+	for i := 0; i < 5; i++ {
+		serve(handler, "PATCH", "/game/"+gameId, powergrim.ActionContentType, `{"action": "advancePhase", "meta": {"author": "st"}}`)
+	}
+
+	if got := historyVersions(t, handler, "/game/"+gameId+"/history"); len(got) != 3 || got[0] != 4 || got[2] != 6 {
+		t.Errorf("history has versions %v; expected [4 5 6]", got)
+	}
+	if got := historyVersions(t, handler, "/game/"+gameId+"/history?since=5"); len(got) != 1 || got[0] != 6 {
+		t.Errorf("history since 5 has versions %v; expected [6]", got)
+	}
+	if got := historyVersions(t, handler, "/game/"+gameId+"/history?since=6"); len(got) != 0 {
+		t.Errorf("history since 6 has versions %v; expected none", got)
+	}
+	if resp := serve(handler, "GET", "/game/"+gameId+"/history?since=first", "", ""); resp.Code != http.StatusBadRequest {
+		t.Errorf("GET history since a word returned %d; expected 400", resp.Code)
+	}
+	if resp := serve(handler, "GET", "/game/unknown/history", "", ""); resp.Code != http.StatusNotFound {
+		t.Errorf("GET history of an unknown game returned %d; expected 404", resp.Code)
+	}
+}
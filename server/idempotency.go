@@ -0,0 +1,205 @@
+package server
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	ErrIdempotencyKey       = errors.New("Idempotency-Key must have length between 1 and 255")
+	ErrIdempotencyKeyReused = errors.New("Idempotency-Key must not be reused for a different request")
+	ErrIdempotencyKeyInUse  = errors.New("request with the same Idempotency-Key is still being processed")
+)
+
+const maxIdempotencyKeyLength = 255
+
+var replayedHeaders = []string{"Content-Type", "Location", "ETag", "Last-Modified", "Preference-Applied"}
+
+type idempotentResponse struct {
+	element     *list.Element
+	fingerprint [sha256.Size]byte
+	done        bool
+	expires     time.Time
+	status      int
+	header      http.Header
+	body        []byte
+}
+
+// IdempotencyCache remembers the responses to requests with an
+// Idempotency-Key header for a window, so that a retried request gets the
+// original response instead of being processed twice. When it holds
+// maxResponses responses, the oldest one is forgotten.
+type IdempotencyCache struct {
+	window       time.Duration
+	maxResponses int
+	mut          sync.Mutex
+	responses    map[string]*idempotentResponse
+	order        *list.List
+	lastSweep    time.Time
+}
+
+func NewIdempotencyCache(window time.Duration, maxResponses int) *IdempotencyCache {
+	return &IdempotencyCache{
+		window:       window,
+		maxResponses: maxResponses,
+		responses:    make(map[string]*idempotentResponse),
+		order:        list.New(),
+	}
+}
+
+var idempotencyCache *IdempotencyCache
+
+// begin looks up the response for key. If there is none, it reserves key for
+// the caller, which must call finish or abandon.
+func (c *IdempotencyCache) begin(key string, fingerprint [sha256.Size]byte, now time.Time) (*idempotentResponse, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if now.Sub(c.lastSweep) > c.window {
+		c.sweep(now)
+	}
+	response, ok := c.responses[key]
+	switch {
+	case !ok || response.done && now.After(response.expires):
+		if ok {
+			c.remove(key)
+		}
+		if len(c.responses) >= c.maxResponses {
+			c.evict()
+		}
+		c.responses[key] = &idempotentResponse{element: c.order.PushBack(key), fingerprint: fingerprint}
+		return nil, nil
+	case response.fingerprint != fingerprint:
+		return nil, ErrIdempotencyKeyReused
+	case !response.done:
+		return nil, ErrIdempotencyKeyInUse
+	default:
+		return response, nil
+	}
+}
+
+func (c *IdempotencyCache) finish(key string, recorder *statusRecorder, now time.Time) {
+	header := make(http.Header)
+	for _, name := range replayedHeaders {
+		if values := recorder.Header().Values(name); len(values) != 0 {
+			header[name] = values
+		}
+	}
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	response := c.responses[key]
+	response.done = true
+	response.expires = now.Add(c.window)
+	response.status = recorder.status
+	response.header = header
+	response.body = recorder.body.Bytes()
+}
+
+func (c *IdempotencyCache) abandon(key string) {
+	c.mut.Lock()
+	c.remove(key)
+	c.mut.Unlock()
+}
+
+func (c *IdempotencyCache) remove(key string) {
+	if response, ok := c.responses[key]; ok {
+		c.order.Remove(response.element)
+		delete(c.responses, key)
+	}
+}
+
+func (c *IdempotencyCache) sweep(now time.Time) {
+	for key, response := range c.responses {
+		if response.done && now.After(response.expires) {
+			c.remove(key)
+		}
+	}
+	c.lastSweep = now
+}
+
+// evict forgets the oldest response, skipping requests that are still being
+// processed.
+func (c *IdempotencyCache) evict() {
+	for element := c.order.Front(); element != nil; element = element.Next() {
+		key := element.Value.(string)
+		if c.responses[key].done {
+			c.remove(key)
+			return
+		}
+	}
+}
+
+// Handler makes handler idempotent for requests with an Idempotency-Key
+// header. Responses are kept per method, path and key, except for server
+// errors and rate limiting, which are safe to retry.
+func (c *IdempotencyCache) Handler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		values := req.Header.Values("Idempotency-Key")
+		if len(values) == 0 {
+			handler.ServeHTTP(resp, req)
+			return
+		}
+		idempotencyKey := values[0]
+		if len(values) != 1 || len(idempotencyKey) == 0 || len(idempotencyKey) > maxIdempotencyKeyLength {
+			writeProblem(resp, NewProblem(http.StatusBadRequest, ErrIdempotencyKey))
+			return
+		}
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			decodeFailed(resp, err)
+			return
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		hash := sha256.New()
+		for _, field := range []string{req.Header.Get("Content-Type"), req.Header.Get("If-Match"), req.Header.Get("If-Unmodified-Since")} {
+			io.WriteString(hash, field)
+			hash.Write([]byte{0})
+		}
+		hash.Write(body)
+		var fingerprint [sha256.Size]byte
+		hash.Sum(fingerprint[:0])
+
+		key := req.Method + " " + req.URL.Path + " " + idempotencyKey
+		response, err := c.begin(key, fingerprint, time.Now())
+		switch {
+		case errors.Is(err, ErrIdempotencyKeyReused):
+			writeProblem(resp, NewProblem(http.StatusUnprocessableEntity, err))
+			return
+		case err != nil:
+			writeProblem(resp, NewProblem(http.StatusConflict, err))
+			return
+		case response != nil:
+			header := resp.Header()
+			for name, values := range response.header {
+				header[name] = values
+			}
+			header.Set("Idempotent-Replayed", "true")
+			resp.WriteHeader(response.status)
+			resp.Write(response.body)
+			return
+		}
+
+		recorder := &statusRecorder{ResponseWriter: resp, body: new(bytes.Buffer)}
+		completed := false
+		defer func() {
+			if !completed {
+				c.abandon(key)
+			}
+		}()
+		handler.ServeHTTP(recorder, req)
+		completed = true
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+		if recorder.status >= 500 || recorder.status == http.StatusTooManyRequests {
+			c.abandon(key)
+			return
+		}
+		c.finish(key, recorder, time.Now())
+	})
+}
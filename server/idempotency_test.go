@@ -0,0 +1,97 @@
+package server_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	powergrim "github.com/phedny/powergrim-server/server"
+)
+
+func countingHandler(count *int, status int) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		*count++
+		body, _ := io.ReadAll(req.Body)
+		resp.Header().Set("Location", fmt.Sprintf("/game/%d", *count))
+		resp.WriteHeader(status)
+		fmt.Fprintf(resp, "%d %s", *count, body)
+	})
+}
+
+func idempotentRequest(handler http.Handler, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/game", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	return resp
+}
+
+func TestIdempotencyReplay(t *testing.T) {
+	count := 0
+	handler := powergrim.NewIdempotencyCache(time.Hour, 100).Handler(countingHandler(&count, http.StatusCreated))
+
+	first := idempotentRequest(handler, "a", "x")
+	second := idempotentRequest(handler, "a", "x")
+	if count != 1 {
+		t.Fatalf("handler was called %d times; expected once", count)
+	}
+	if second.Code != http.StatusCreated || second.Body.String() != first.Body.String() || second.Header().Get("Location") != "/game/1" {
+		t.Fatalf("replay returned %d %q with Location %q", second.Code, second.Body, second.Header().Get("Location"))
+	}
+	if second.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("replay is missing Idempotent-Replayed header")
+	}
+
+	if resp := idempotentRequest(handler, "a", "y"); resp.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("reusing key for another body returned %d; expected 422", resp.Code)
+	}
+	idempotentRequest(handler, "b", "x")
+	idempotentRequest(handler, "", "x")
+	if count != 3 {
+		t.Fatalf("handler was called %d times; expected 3", count)
+	}
+}
+
+func TestIdempotencyServerErrorIsRetried(t *testing.T) {
+	count := 0
+	handler := powergrim.NewIdempotencyCache(time.Hour, 100).Handler(countingHandler(&count, http.StatusInternalServerError))
+	idempotentRequest(handler, "a", "x")
+	idempotentRequest(handler, "a", "x")
+	if count != 2 {
+		t.Fatalf("handler was called %d times; expected twice", count)
+	}
+}
+
+func TestIdempotencyWindow(t *testing.T) {
+	count := 0
+	handler := powergrim.NewIdempotencyCache(time.Nanosecond, 100).Handler(countingHandler(&count, http.StatusOK))
+	idempotentRequest(handler, "a", "x")
+	time.Sleep(time.Millisecond)
+	idempotentRequest(handler, "a", "x")
+	if count != 2 {
+		t.Fatalf("handler was called %d times; expected twice", count)
+	}
+}
+
+func TestIdempotencyEviction(t *testing.T) {
+	count := 0
+	handler := powergrim.NewIdempotencyCache(time.Hour, 2).Handler(countingHandler(&count, http.StatusCreated))
+	idempotentRequest(handler, "a", "x")
+	idempotentRequest(handler, "b", "x")
+	idempotentRequest(handler, "c", "x")
+	if resp := idempotentRequest(handler, "c", "x"); resp.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("newest response was not replayed")
+	}
+	if count != 3 {
+		t.Fatalf("handler was called %d times; expected 3", count)
+	}
+	if resp := idempotentRequest(handler, "a", "x"); resp.Header().Get("Idempotent-Replayed") != "" || count != 4 {
+		t.Fatalf("oldest response was replayed after the cache was full")
+	}
+}
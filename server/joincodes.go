@@ -0,0 +1,266 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/phedny/powergrim-server/grimoire"
+)
+
+var (
+	ErrJoinCodeSpace   = errors.New("no unused join code found")
+	ErrJoinCodeExpired = errors.New("join code must be regenerated")
+	ErrJoinSeat        = errors.New("seat must be absent or id of existing player")
+)
+
+const (
+	JoinCodeContentType   = "application/prs.powergrim.joinCode+json; charset=utf-8"
+	JoinedGameContentType = "application/prs.powergrim.joinedGame+json; charset=utf-8"
+	joinCodeAlphabet      = "ABCDEFGHJKMNPQRSTUVWXYZ"
+	joinCodeLength        = 6
+	maxJoinCodeAttempts   = 10
+	joinCodeRejectAbove   = 256 - 256%len(joinCodeAlphabet)
+)
+
+// JoinCode is a short code that can be read aloud to let people join a game.
+// It leaves out letters that are easily confused, such as I, L and O.
+type JoinCode struct {
+	Code      string    `json:"code"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+func (code JoinCode) Valid(now time.Time) bool {
+	return code.Code != "" && now.Before(code.ExpiresAt)
+}
+
+// JoinCodeRegistry maps join codes to games. Every game has at most one code.
+type JoinCodeRegistry struct {
+	ttl    time.Duration
+	random io.Reader
+	mut    sync.Mutex
+	codes  map[string]joinCodeEntry
+	byGame map[string]string
+}
+
+type joinCodeEntry struct {
+	gameId    string
+	expiresAt time.Time
+}
+
+// NewJoinCodeRegistry returns a registry that hands out codes valid for ttl,
+// generated from random.
+func NewJoinCodeRegistry(ttl time.Duration, random io.Reader) *JoinCodeRegistry {
+	return &JoinCodeRegistry{
+		ttl:    ttl,
+		random: random,
+		codes:  make(map[string]joinCodeEntry),
+		byGame: make(map[string]string),
+	}
+}
+
+var joinCodes *JoinCodeRegistry
+
+// Restore registers a code that was allocated before, such as one loaded from
+// storage. Expired codes and codes that are taken are ignored.
+func (r *JoinCodeRegistry) Restore(gameId string, code JoinCode, now time.Time) {
+	if !code.Valid(now) {
+		return
+	}
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	if entry, ok := r.codes[code.Code]; ok && now.Before(entry.expiresAt) {
+		return
+	}
+	r.remove(gameId)
+	r.codes[code.Code] = joinCodeEntry{gameId, code.ExpiresAt}
+	r.byGame[gameId] = code.Code
+}
+
+// Allocate gives the game a new code, replacing the code it had before.
+// Codes of other games that have expired can be handed out again.
+func (r *JoinCodeRegistry) Allocate(gameId string, now time.Time) (JoinCode, error) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	for attempt := 0; attempt < maxJoinCodeAttempts; attempt++ {
+		code, err := r.generate()
+		if err != nil {
+			return JoinCode{}, err
+		}
+		if entry, ok := r.codes[code]; ok && now.Before(entry.expiresAt) {
+			continue
+		} else if ok {
+			r.remove(entry.gameId)
+		}
+		r.remove(gameId)
+		expiresAt := now.Add(r.ttl).Truncate(time.Second)
+		r.codes[code] = joinCodeEntry{gameId, expiresAt}
+		r.byGame[gameId] = code
+		return JoinCode{code, expiresAt}, nil
+	}
+	return JoinCode{}, ErrJoinCodeSpace
+}
+
+// Resolve returns the game of a code that has not expired. Codes are
+// case-insensitive.
+func (r *JoinCodeRegistry) Resolve(code string, now time.Time) (string, bool) {
+	code = strings.ToUpper(code)
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	entry, ok := r.codes[code]
+	if !ok {
+		return "", false
+	}
+	if !now.Before(entry.expiresAt) {
+		r.remove(entry.gameId)
+		return "", false
+	}
+	return entry.gameId, true
+}
+
+func (r *JoinCodeRegistry) remove(gameId string) {
+	if code, ok := r.byGame[gameId]; ok {
+		delete(r.codes, code)
+		delete(r.byGame, gameId)
+	}
+}
+
+// generate returns a random code, rejecting bytes that would make some
+// letters more likely than others.
+func (r *JoinCodeRegistry) generate() (string, error) {
+	code := make([]byte, 0, joinCodeLength)
+	buf := make([]byte, joinCodeLength)
+	for len(code) < joinCodeLength {
+		if _, err := io.ReadFull(r.random, buf); err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			if int(b) < joinCodeRejectAbove && len(code) < joinCodeLength {
+				code = append(code, joinCodeAlphabet[int(b)%len(joinCodeAlphabet)])
+			}
+		}
+	}
+	return string(code), nil
+}
+
+// JoinedGame is the view of a game for people who joined it with a code. It
+// leaves out the characters and alignments of the players and the reminders,
+// which only the storyteller may see. Seat is the player that the join link
+// was made for, if any.
+type JoinedGame struct {
+	Script  string         `json:"script"`
+	Layout  string         `json:"layout,omitempty"`
+	Phase   int            `json:"phase,omitempty"`
+	Players []JoinedPlayer `json:"players"`
+	Seat    int            `json:"seat,omitempty"`
+}
+
+type JoinedPlayer struct {
+	Id         int    `json:"id"`
+	Position   [2]int `json:"position"`
+	Alive      bool   `json:"alive"`
+	GhostVotes uint   `json:"ghostVotes,omitempty"`
+}
+
+func newJoinedGame(game grimoire.Game, seat int) JoinedGame {
+	players := make([]JoinedPlayer, len(game.Players))
+	for i, player := range game.Players {
+		players[i] = JoinedPlayer{
+			Id:         player.Id,
+			Position:   player.Position,
+			Alive:      player.Alive,
+			GhostVotes: player.GhostVotes,
+		}
+	}
+	return JoinedGame{
+		Script:  game.Script,
+		Layout:  game.Layout,
+		Phase:   game.Phase,
+		Players: players,
+		Seat:    seat,
+	}
+}
+
+// parseSeat returns the player id in the seat query parameter, or 0 if there
+// is none. It reports whether the parameter is absent or names a player.
+func parseSeat(query url.Values, game grimoire.Game) (int, bool) {
+	if !query.Has("seat") {
+		return 0, true
+	}
+	id, err := strconv.Atoi(query.Get("seat"))
+	if err != nil || !slices.ContainsFunc(game.Players, grimoire.PlayerWithId(id)) {
+		return 0, false
+	}
+	return id, true
+}
+
+// joinGame serves the game of a join code without revealing its id, so that
+// people who joined with the code can follow the game but not change it, and
+// lose access when the code is regenerated or expires. Join links made for a
+// seat name it in the seat query parameter.
+func joinGame(resp http.ResponseWriter, req *http.Request) {
+	gameId, ok := joinCodes.Resolve(req.PathValue("code"), time.Now())
+	if !ok {
+		writeProblem(resp, NewProblem(http.StatusNotFound, nil))
+		return
+	}
+	game, ok := games.Get(gameId)
+	if !ok {
+		writeProblem(resp, NewProblem(http.StatusNotFound, nil))
+		return
+	}
+	seat, ok := parseSeat(req.URL.Query(), game.Game)
+	if !ok {
+		writeProblem(resp, NewProblem(http.StatusBadRequest, ErrJoinSeat))
+		return
+	}
+	resp.Header().Add("Vary", "Accept-Encoding")
+	if !checkPreconditions(resp, req, game.validators()) {
+		return
+	}
+	data, err := json.Marshal(newJoinedGame(game.Game, seat))
+	if err != nil {
+		writeProblem(resp, NewProblem(http.StatusInternalServerError, err))
+		return
+	}
+	resp.Header().Add("Content-Type", JoinedGameContentType)
+	writeEncoded(resp, req, append(data, '\n'))
+}
+
+func getJoinCode(resp http.ResponseWriter, req *http.Request) {
+	game, ok := games.Get(req.PathValue("gameId"))
+	if !ok || game.JoinCode.Code == "" {
+		writeProblem(resp, NewProblem(http.StatusNotFound, nil))
+		return
+	}
+	resp.Header().Add("Content-Type", JoinCodeContentType)
+	json.NewEncoder(resp).Encode(game.JoinCode)
+}
+
+// regenerateJoinCode gives the game a new code, so that the old code no
+// longer lets people join.
+func regenerateJoinCode(resp http.ResponseWriter, req *http.Request) {
+	gameId := req.PathValue("gameId")
+	game, ok, err := games.Update(gameId, func(game VersionedGame) (VersionedGame, error) {
+		code, err := joinCodes.Allocate(gameId, time.Now())
+		game.JoinCode = code
+		return game, err
+	})
+	if !ok {
+		writeProblem(resp, NewProblem(http.StatusNotFound, nil))
+		return
+	}
+	if err != nil {
+		writeProblem(resp, NewProblem(http.StatusServiceUnavailable, err))
+		return
+	}
+	resp.Header().Add("Content-Type", JoinCodeContentType)
+	json.NewEncoder(resp).Encode(game.JoinCode)
+}
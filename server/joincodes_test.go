@@ -0,0 +1,168 @@
+package server_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	powergrim "github.com/phedny/powergrim-server/server"
+)
+
+func TestJoinCodeAllocate(t *testing.T) {
+	now := time.Date(2024, 3, 1, 20, 0, 0, 0, time.UTC)
+	registry := powergrim.NewJoinCodeRegistry(time.Hour, rand.Reader)
+	code, err := registry.Allocate("game-1", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(code.Code) != 6 || strings.Trim(code.Code, "ABCDEFGHJKMNPQRSTUVWXYZ") != "" {
+		t.Errorf("Allocate() returned code %q", code.Code)
+	}
+	if !code.ExpiresAt.Equal(now.Add(time.Hour)) {
+		t.Errorf("Allocate() returned code expiring at %s", code.ExpiresAt)
+	}
+	if gameId, ok := registry.Resolve(strings.ToLower(code.Code), now); !ok || gameId != "game-1" {
+		t.Errorf("Resolve(%q) = %q, %t, expected game-1", strings.ToLower(code.Code), gameId, ok)
+	}
+	if _, ok := registry.Resolve(code.Code, now.Add(time.Hour)); ok {
+		t.Errorf("Resolve(%q) resolved an expired code", code.Code)
+	}
+}
+
+func TestJoinCodeRegenerate(t *testing.T) {
+	now := time.Now()
+	registry := powergrim.NewJoinCodeRegistry(time.Hour, bytes.NewReader([]byte("aaaaaabbbbbb")))
+	old, err := registry.Allocate("game-1", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := registry.Allocate("game-1", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := registry.Resolve(old.Code, now); ok {
+		t.Errorf("Resolve(%q) resolved a replaced code", old.Code)
+	}
+	if gameId, ok := registry.Resolve(code.Code, now); !ok || gameId != "game-1" {
+		t.Errorf("Resolve(%q) = %q, %t, expected game-1", code.Code, gameId, ok)
+	}
+}
+
+func TestJoinCodeCollision(t *testing.T) {
+	now := time.Now()
+	registry := powergrim.NewJoinCodeRegistry(time.Hour, bytes.NewReader([]byte("aaaaaaaaaaaabbbbbb")))
+	first, err := registry.Allocate("game-1", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := registry.Allocate("game-2", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Code == second.Code {
+		t.Errorf("Allocate() returned %q twice", first.Code)
+	}
+
+	registry = powergrim.NewJoinCodeRegistry(time.Hour, bytes.NewReader(bytes.Repeat([]byte{0}, 100)))
+	if _, err := registry.Allocate("game-1", now); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := registry.Allocate("game-2", now); !errors.Is(err, powergrim.ErrJoinCodeSpace) {
+		t.Errorf("Allocate() returned %v, expected ErrJoinCodeSpace", err)
+	}
+}
+
+func TestJoinCodeReuseExpired(t *testing.T) {
+	now := time.Now()
+	registry := powergrim.NewJoinCodeRegistry(time.Hour, bytes.NewReader([]byte("aaaaaaaaaaaa")))
+	first, err := registry.Allocate("game-1", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := registry.Allocate("game-2", now.Add(2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Code != second.Code {
+		t.Errorf("Allocate() returned %q, expected expired code %q", second.Code, first.Code)
+	}
+	if gameId, ok := registry.Resolve(second.Code, now.Add(2*time.Hour)); !ok || gameId != "game-2" {
+		t.Errorf("Resolve(%q) = %q, %t, expected game-2", second.Code, gameId, ok)
+	}
+}
+
+func TestJoinCodeRestore(t *testing.T) {
+	now := time.Now()
+	registry := powergrim.NewJoinCodeRegistry(time.Hour, rand.Reader)
+	registry.Restore("game-1", powergrim.JoinCode{Code: "ABCDEF", ExpiresAt: now.Add(time.Minute)}, now)
+	registry.Restore("game-2", powergrim.JoinCode{Code: "ABCDEF", ExpiresAt: now.Add(time.Hour)}, now)
+	registry.Restore("game-3", powergrim.JoinCode{Code: "GHJKMN", ExpiresAt: now.Add(-time.Minute)}, now)
+	if gameId, ok := registry.Resolve("ABCDEF", now); !ok || gameId != "game-1" {
+		t.Errorf("Resolve(ABCDEF) = %q, %t, expected game-1", gameId, ok)
+	}
+	if _, ok := registry.Resolve("GHJKMN", now); ok {
+		t.Error("Resolve(GHJKMN) resolved an expired code")
+	}
+}
+
+func joinCode(t *testing.T, handler http.Handler, method, gameId string) string {
+	resp := serve(handler, method, "/game/"+gameId+"/joinCode", "", "")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("%s joinCode returned %d %s", method, resp.Code, resp.Body)
+	}
+	var code powergrim.JoinCode
+	if err := json.NewDecoder(resp.Body).Decode(&code); err != nil {
+		t.Fatal(err)
+	}
+	return code.Code
+}
+
+func TestJoinGame(t *testing.T) {
+	handler := newTestHandler(t, testConfig(t))
+	gameId := createGame(t, handler, `{"script": "tb", "players": [], "reminders": []}`)
+	serve(handler, "PATCH", "/game/"+gameId, powergrim.ActionsContentType, `[
+		{"action": "addPlayer", "id": 1, "character": "imp"},
+		{"action": "addPlayer", "id": 2, "afterPlayer": 1, "character": "monk"},
+		{"action": "addReminder", "character": "monk", "token": "protected", "position": 1}
+	]`)
+	code := joinCode(t, handler, "GET", gameId)
+
+	resp := serve(handler, "GET", "/join/"+strings.ToLower(code)+"?seat=2", "", "")
+	if resp.Code != http.StatusOK || resp.Header().Get("Content-Type") != powergrim.JoinedGameContentType {
+		t.Fatalf("GET /join/%s returned %d %s", code, resp.Code, resp.Body)
+	}
+	body := resp.Body.String()
+	if strings.Contains(body, gameId) || resp.Header().Get("Location") != "" {
+		t.Fatalf("GET /join/%s revealed the game id", code)
+	}
+	for _, secret := range []string{"imp", "protected"} {
+		if strings.Contains(body, secret) {
+			t.Fatalf("GET /join/%s revealed %q: %s", code, secret, body)
+		}
+	}
+	var joined powergrim.JoinedGame
+	if err := json.Unmarshal([]byte(body), &joined); err != nil {
+		t.Fatal(err)
+	}
+	if joined.Script != "tb" || len(joined.Players) != 2 || joined.Seat != 2 {
+		t.Fatalf("GET /join/%s returned %+v", code, joined)
+	}
+	for _, seat := range []string{"3", "first"} {
+		if resp := serve(handler, "GET", "/join/"+code+"?seat="+seat, "", ""); resp.Code != http.StatusBadRequest {
+			t.Fatalf("GET /join/%s?seat=%s returned %d; expected 400", code, seat, resp.Code)
+		}
+	}
+
+	newCode := joinCode(t, handler, "POST", gameId)
+	if resp := serve(handler, "GET", "/join/"+code, "", ""); resp.Code != http.StatusNotFound {
+		t.Fatalf("GET /join with a regenerated code returned %d; expected 404", resp.Code)
+	}
+	if resp := serve(handler, "GET", "/join/"+newCode, "", ""); resp.Code != http.StatusOK {
+		t.Fatalf("GET /join with the new code returned %d; expected 200", resp.Code)
+	}
+}
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/phedny/powergrim-server/grimoire"
+)
+
+var (
+	ErrTooManyActions   = errors.New("too many actions in batch")
+	ErrTooManyPlayers   = errors.New("too many players in game")
+	ErrTooManyReminders = errors.New("too many reminders in game")
+)
+
+const rateLimiterSweepInterval = time.Minute
+
+var bodyLimits = map[string]int64{
+	GameContentType:    64 << 10,
+	ActionContentType:  4 << 10,
+	ActionsContentType: 256 << 10,
+	WebhookContentType: 4 << 10,
+}
+
+func bodyLimit(contentType string) int64 {
+	if limit, ok := bodyLimits[contentType]; ok {
+		return min(limit, config.MaxBodyBytes)
+	}
+	return config.MaxBodyBytes
+}
+
+func limitBodySize(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		req.Body = http.MaxBytesReader(resp, req.Body, bodyLimit(req.Header.Get("Content-Type")))
+		handler.ServeHTTP(resp, req)
+	})
+}
+
+func decodeFailed(resp http.ResponseWriter, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		writeProblem(resp, NewProblem(http.StatusRequestEntityTooLarge, err))
+		return
+	}
+	writeProblem(resp, NewProblem(http.StatusBadRequest, err))
+}
+
+func checkGameLimits(game grimoire.Game) error {
+	if config.MaxPlayers > 0 && len(game.Players) > config.MaxPlayers {
+		return ErrTooManyPlayers
+	}
+	if config.MaxReminders > 0 && len(game.Reminders) > config.MaxReminders {
+		return ErrTooManyReminders
+	}
+	return nil
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type RateLimiter struct {
+	rate      float64
+	burst     float64
+	mut       sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// Allow takes a token from the bucket for key, returning false and the time
+// until the next token is available when the bucket is empty. A limiter with a
+// rate of zero allows everything.
+func (rl *RateLimiter) Allow(key string, now time.Time) (bool, time.Duration) {
+	if rl.rate <= 0 {
+		return true, 0
+	}
+	rl.mut.Lock()
+	defer rl.mut.Unlock()
+	if now.Sub(rl.lastSweep) > rateLimiterSweepInterval {
+		rl.sweep(now)
+	}
+	bucket, ok := rl.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: rl.burst, last: now}
+		rl.buckets[key] = bucket
+	}
+	bucket.tokens = math.Min(rl.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*rl.rate)
+	bucket.last = now
+	if bucket.tokens < 1 {
+		return false, time.Duration((1 - bucket.tokens) / rl.rate * float64(time.Second))
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+func (rl *RateLimiter) sweep(now time.Time) {
+	full := time.Duration(rl.burst / rl.rate * float64(time.Second))
+	for key, bucket := range rl.buckets {
+		if now.Sub(bucket.last) > full {
+			delete(rl.buckets, key)
+		}
+	}
+	rl.lastSweep = now
+}
+
+var clientLimiter, gameLimiter *RateLimiter
+
+func clientIp(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+func tooManyRequests(resp http.ResponseWriter, retryAfter time.Duration) {
+	resp.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	writeProblem(resp, NewProblem(http.StatusTooManyRequests, nil))
+}
+
+func limitClientRate(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			if ok, retryAfter := clientLimiter.Allow(clientIp(req), time.Now()); !ok {
+				tooManyRequests(resp, retryAfter)
+				return
+			}
+		}
+		handler.ServeHTTP(resp, req)
+	})
+}
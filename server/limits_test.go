@@ -0,0 +1,49 @@
+package server_test
+
+import (
+	"testing"
+	"time"
+
+	powergrim "github.com/phedny/powergrim-server/server"
+)
+
+func TestRateLimiterBurst(t *testing.T) {
+	limiter := powergrim.NewRateLimiter(1, 3)
+	now := time.Unix(1000, 0)
+	for i := 0; i < 3; i++ {
+		if ok, _ := limiter.Allow("a", now); !ok {
+			t.Fatalf("Allow() rejected request %d within burst", i)
+		}
+	}
+	ok, retryAfter := limiter.Allow("a", now)
+	if ok || retryAfter != time.Second {
+		t.Fatalf("Allow() returned (%t, %s); expected (false, 1s)", ok, retryAfter)
+	}
+	if ok, _ := limiter.Allow("b", now); !ok {
+		t.Fatalf("Allow() rejected a request for another key")
+	}
+}
+
+func TestRateLimiterRefill(t *testing.T) {
+	limiter := powergrim.NewRateLimiter(2, 1)
+	now := time.Unix(1000, 0)
+	if ok, _ := limiter.Allow("a", now); !ok {
+		t.Fatalf("Allow() rejected the first request")
+	}
+	if ok, _ := limiter.Allow("a", now.Add(250*time.Millisecond)); ok {
+		t.Fatalf("Allow() accepted a request before the bucket refilled")
+	}
+	if ok, _ := limiter.Allow("a", now.Add(500*time.Millisecond)); !ok {
+		t.Fatalf("Allow() rejected a request after the bucket refilled")
+	}
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	limiter := powergrim.NewRateLimiter(0, 0)
+	now := time.Unix(1000, 0)
+	for i := 0; i < 100; i++ {
+		if ok, _ := limiter.Allow("a", now); !ok {
+			t.Fatalf("Allow() rejected request %d on a disabled limiter", i)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/phedny/powergrim-server/grimoire"
+)
+
+const maxRequestIdLength = 64
+
+type requestIdKey struct{}
+
+func NewLogger(w io.Writer, level, format string) (*slog.Logger, error) {
+	var logLevel slog.Level
+	if err := logLevel.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("%w: logLevel: %s", ErrInvalidConfig, err)
+	}
+	options := &slog.HandlerOptions{Level: logLevel}
+	switch format {
+	case "text":
+		return slog.New(slog.NewTextHandler(w, options)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, options)), nil
+	default:
+		return nil, fmt.Errorf("%w: logFormat must be text or json", ErrInvalidConfig)
+	}
+}
+
+func requestId(ctx context.Context) string {
+	id, _ := ctx.Value(requestIdKey{}).(string)
+	return id
+}
+
+func newRequestId() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+func validRequestId(id string) bool {
+	return id != "" && len(id) <= maxRequestIdLength && !strings.ContainsFunc(id, func(r rune) bool {
+		return r <= ' ' || r > '~'
+	})
+}
+
+func logRequests(mux *http.ServeMux, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		id := req.Header.Get("X-Request-Id")
+		if !validRequestId(id) {
+			id = newRequestId()
+		}
+		resp.Header().Set("X-Request-Id", id)
+		req = req.WithContext(context.WithValue(req.Context(), requestIdKey{}, id))
+		recorder := &statusRecorder{ResponseWriter: resp}
+		handler.ServeHTTP(recorder, req)
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+		_, route := mux.Handler(req)
+		slog.InfoContext(req.Context(), "request",
+			"request_id", id,
+			"method", req.Method,
+			"route", route,
+			"path", req.URL.Path,
+			"status", recorder.status,
+			"duration", time.Since(start),
+		)
+	})
+}
+
+func auditActions(ctx context.Context, gameId string, versionBefore, versionAfter int, actions []grimoire.WrappedAction) {
+	for actionIdx, action := range actions {
+		args := []any{
+			"log", "audit",
+			"request_id", requestId(ctx),
+			"game_id", gameId,
+			"version_before", versionBefore,
+			"version_after", versionAfter,
+			"action_index", actionIdx,
+			"action", grimoire.ActionName(action.Action),
+		}
+		if action.Meta.Author != "" {
+			args = append(args, "author", action.Meta.Author)
+		}
+		if action.Meta.Note != "" {
+			args = append(args, "note", action.Meta.Note)
+		}
+		slog.InfoContext(ctx, "action applied", args...)
+	}
+}
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// preferredWait returns the duration of the wait preference of req, capped
+// by config.MaxWait.
+func preferredWait(req *http.Request) (time.Duration, bool) {
+	value, ok := Preferences(req)["wait"]
+	if !ok || config.MaxWait <= 0 {
+		return 0, false
+	}
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds <= 0 {
+		return 0, false
+	}
+	return min(time.Duration(seconds)*time.Second, time.Duration(config.MaxWait)), true
+}
+
+// waitForChange blocks until changed is closed, the deadline passes, the
+// client goes away or the server shuts down. It returns whether changed was
+// closed and whether the request should still be answered.
+func waitForChange(ctx context.Context, changed <-chan struct{}, deadline time.Time) (bool, bool) {
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case <-changed:
+		return true, true
+	case <-timer.C:
+	case <-shuttingDown:
+	case <-ctx.Done():
+		return false, false
+	}
+	return false, true
+}
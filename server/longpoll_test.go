@@ -0,0 +1,62 @@
+package server_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	powergrim "github.com/phedny/powergrim-server/server"
+)
+
+func longPoll(handler http.Handler, gameId, etag, wait string) <-chan *httptest.ResponseRecorder {
+	result := make(chan *httptest.ResponseRecorder, 1)
+	go func() {
+		req := httptest.NewRequest("GET", "/game/"+gameId, nil)
+		req.Header.Set("If-None-Match", etag)
+		req.Header.Set("Prefer", "wait="+wait)
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		result <- resp
+	}()
+	return result
+}
+
+func TestLongPollTimeout(t *testing.T) {
+	handler := newTestHandler(t, testConfig(t))
+	gameId := createGame(t, handler, `{"script": "tb", "players": [], "reminders": []}`)
+	etag := serve(handler, "GET", "/game/"+gameId, "", "").Header().Get("ETag")
+
+	start := time.Now()
+	resp := <-longPoll(handler, gameId, etag, "1")
+	if resp.Code != http.StatusNotModified || resp.Header().Get("Preference-Applied") != "wait=1" {
+		t.Fatalf("long poll returned %d with Preference-Applied %q", resp.Code, resp.Header().Get("Preference-Applied"))
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Fatalf("long poll returned after %s; expected to wait 1s", elapsed)
+	}
+}
+
+func TestLongPollWakesOnPatch(t *testing.T) {
+	handler := newTestHandler(t, testConfig(t))
+	gameId := createGame(t, handler, `{"script": "tb", "players": [], "reminders": []}`)
+	etag := serve(handler, "GET", "/game/"+gameId, "", "").Header().Get("ETag")
+
+	result := longPoll(handler, gameId, etag, "10")
+	time.Sleep(50 * time.Millisecond)
+	serve(handler, "POST", "/game/"+gameId+"/joinCode", "", "")
+	select {
+	case resp := <-result:
+		t.Fatalf("long poll returned %d after the join code changed", resp.Code)
+	case <-time.After(50 * time.Millisecond):
+	}
+	serve(handler, "PATCH", "/game/"+gameId, powergrim.ActionContentType, `{"action": "advancePhase"}`)
+	select {
+	case resp := <-result:
+		if resp.Code != http.StatusOK || resp.Header().Get("ETag") == etag {
+			t.Fatalf("long poll returned %d with ETag %q", resp.Code, resp.Header().Get("ETag"))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("long poll did not return after PATCH")
+	}
+}
@@ -0,0 +1,258 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const MetricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+var defaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type metric interface {
+	writeTo(w io.Writer)
+}
+
+type metricFamily struct {
+	name       string
+	help       string
+	metricType string
+	labelNames []string
+}
+
+func (mf metricFamily) writeHeader(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", mf.name, mf.help, mf.name, mf.metricType)
+}
+
+func (mf metricFamily) labels(labelValues []string, extra ...string) string {
+	if len(labelValues) != len(mf.labelNames) {
+		panic(fmt.Sprintf("metric %s expects %d labels, got %d", mf.name, len(mf.labelNames), len(labelValues)))
+	}
+	pairs := make([]string, 0, len(labelValues)+len(extra)/2)
+	for i, name := range mf.labelNames {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, name, escapeLabelValue(labelValues[i])))
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extra[i], escapeLabelValue(extra[i+1])))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+type CounterVec struct {
+	metricFamily
+	mut    sync.Mutex
+	values map[string]float64
+}
+
+func NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	return &CounterVec{
+		metricFamily: metricFamily{name, help, "counter", labelNames},
+		values:       make(map[string]float64),
+	}
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	labels := c.labels(labelValues)
+	c.mut.Lock()
+	c.values[labels] += delta
+	c.mut.Unlock()
+}
+
+func (c *CounterVec) writeTo(w io.Writer) {
+	c.writeHeader(w)
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	for _, labels := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, labels, formatFloat(c.values[labels]))
+	}
+}
+
+type GaugeFunc struct {
+	metricFamily
+	value func() float64
+}
+
+func NewGaugeFunc(name, help string, value func() float64) *GaugeFunc {
+	return &GaugeFunc{metricFamily{name, help, "gauge", nil}, value}
+}
+
+func (g *GaugeFunc) writeTo(w io.Writer) {
+	g.writeHeader(w)
+	fmt.Fprintf(w, "%s %s\n", g.name, formatFloat(g.value()))
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+type HistogramVec struct {
+	metricFamily
+	buckets []float64
+	mut     sync.Mutex
+	values  map[string]*histogram
+	labels  map[string][]string
+}
+
+func NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	return &HistogramVec{
+		metricFamily: metricFamily{name, help, "histogram", labelNames},
+		buckets:      buckets,
+		values:       make(map[string]*histogram),
+		labels:       make(map[string][]string),
+	}
+}
+
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	key := h.metricFamily.labels(labelValues)
+	h.mut.Lock()
+	defer h.mut.Unlock()
+	hist, ok := h.values[key]
+	if !ok {
+		hist = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.values[key] = hist
+		h.labels[key] = slices.Clone(labelValues)
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			hist.counts[i]++
+		}
+	}
+	hist.count++
+	hist.sum += value
+}
+
+func (h *HistogramVec) writeTo(w io.Writer) {
+	h.writeHeader(w)
+	h.mut.Lock()
+	defer h.mut.Unlock()
+	for _, key := range sortedKeys(h.values) {
+		hist, labelValues := h.values[key], h.labels[key]
+		for i, bound := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.metricFamily.labels(labelValues, "le", formatFloat(bound)), hist.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.metricFamily.labels(labelValues, "le", "+Inf"), hist.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, key, formatFloat(hist.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, key, hist.count)
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func WriteMetrics(w io.Writer, metrics ...metric) {
+	for _, m := range metrics {
+		m.writeTo(w)
+	}
+}
+
+var (
+	requestsTotal       = NewCounterVec("powergrim_http_requests_total", "Number of HTTP requests by route, method and status code.", "route", "method", "status")
+	requestDuration     = NewHistogramVec("powergrim_http_request_duration_seconds", "Latency of HTTP requests by route.", defaultBuckets, "route")
+	conditionalRequests = NewCounterVec("powergrim_conditional_requests_total", "Number of conditional GET requests by route and whether they were answered with 304 Not Modified.", "route", "result")
+	patchConflicts      = NewCounterVec("powergrim_patch_conflicts_total", "Number of PATCH requests rejected because the game changed, by status code.", "status")
+	actionsApplied      = NewCounterVec("powergrim_actions_applied_total", "Number of actions applied to games by action type.", "action")
+	actionErrors        = NewCounterVec("powergrim_action_errors_total", "Number of actions rejected by error.", "error")
+	webhookDeliveries   = NewCounterVec("powergrim_webhook_deliveries_total", "Number of webhook deliveries by result.", "result")
+	gamesCount          = NewGaugeFunc("powergrim_games", "Number of games held by the server.", func() float64 {
+		return float64(games.Len())
+	})
+)
+
+func serveMetrics(resp http.ResponseWriter, req *http.Request) {
+	resp.Header().Add("Content-Type", MetricsContentType)
+	WriteMetrics(resp, requestsTotal, requestDuration, conditionalRequests, patchConflicts, actionsApplied, actionErrors, webhookDeliveries, gamesCount)
+}
+
+// statusRecorder records the status code of a response and, if body is not
+// nil, a copy of its body.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	body   *bytes.Buffer
+}
+
+func (sr *statusRecorder) WriteHeader(status int) {
+	if sr.status == 0 {
+		sr.status = status
+	}
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+func (sr *statusRecorder) Write(data []byte) (int, error) {
+	if sr.status == 0 {
+		sr.status = http.StatusOK
+	}
+	if sr.body != nil {
+		sr.body.Write(data)
+	}
+	return sr.ResponseWriter.Write(data)
+}
+
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
+
+// methodLabel returns method if it is a standard HTTP method and "other"
+// otherwise, so that clients cannot create an unbounded number of series.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "other"
+}
+
+func measureRequests(mux *http.ServeMux, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: resp}
+		handler.ServeHTTP(recorder, req)
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+		_, route := mux.Handler(req)
+		if route == "" {
+			route = "unmatched"
+		}
+		requestsTotal.Inc(route, methodLabel(req.Method), strconv.Itoa(recorder.status))
+		requestDuration.Observe(time.Since(start).Seconds(), route)
+		if req.Method == http.MethodGet && (req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "") {
+			if recorder.status == http.StatusNotModified {
+				conditionalRequests.Inc(route, "hit")
+			} else {
+				conditionalRequests.Inc(route, "miss")
+			}
+		}
+	})
+}
@@ -0,0 +1,83 @@
+package server_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	powergrim "github.com/phedny/powergrim-server/server"
+)
+
+func TestWriteCounterVec(t *testing.T) {
+	counter := powergrim.NewCounterVec("test_total", "A test counter.", "route", "status")
+	counter.Inc("GET /a", "200")
+	counter.Inc("GET /a", "200")
+	counter.Add(3, `GET /"b"`, "404")
+
+	var buf bytes.Buffer
+	powergrim.WriteMetrics(&buf, counter)
+	expected := `# HELP test_total A test counter.
+# TYPE test_total counter
+test_total{route="GET /\"b\"",status="404"} 3
+test_total{route="GET /a",status="200"} 2
+`
+	if buf.String() != expected {
+		t.Fatalf("WriteMetrics() wrote %q; expected %q", buf.String(), expected)
+	}
+}
+
+func TestWriteHistogramVec(t *testing.T) {
+	histogram := powergrim.NewHistogramVec("test_seconds", "A test histogram.", []float64{0.1, 1}, "route")
+	histogram.Observe(0.05, "GET /a")
+	histogram.Observe(0.5, "GET /a")
+	histogram.Observe(2, "GET /a")
+
+	var buf bytes.Buffer
+	powergrim.WriteMetrics(&buf, histogram)
+	expected := `# HELP test_seconds A test histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{route="GET /a",le="0.1"} 1
+test_seconds_bucket{route="GET /a",le="1"} 2
+test_seconds_bucket{route="GET /a",le="+Inf"} 3
+test_seconds_sum{route="GET /a"} 2.55
+test_seconds_count{route="GET /a"} 3
+`
+	if buf.String() != expected {
+		t.Fatalf("WriteMetrics() wrote %q; expected %q", buf.String(), expected)
+	}
+}
+
+func TestWriteGaugeFunc(t *testing.T) {
+	gauge := powergrim.NewGaugeFunc("test_things", "A test gauge.", func() float64 { return 42 })
+
+	var buf bytes.Buffer
+	powergrim.WriteMetrics(&buf, gauge)
+	expected := "# HELP test_things A test gauge.\n# TYPE test_things gauge\ntest_things 42\n"
+	if buf.String() != expected {
+		t.Fatalf("WriteMetrics() wrote %q; expected %q", buf.String(), expected)
+	}
+}
+
+func TestMetricsLabels(t *testing.T) {
+	handler := newTestHandler(t, testConfig(t))
+	gameId := createGame(t, handler, `{"script": "tb", "players": [], "reminders": []}`)
+	etag := serve(handler, "GET", "/game/"+gameId, "", "").Header().Get("ETag")
+	patchAction(handler, gameId, `{"action": "addPlayer", "id": 1}`, etag, "")
+	patchAction(handler, gameId, `{"action": "addPlayer", "id": 1}`, etag, "")
+	patchAction(handler, gameId, `{"action": "addPlayer", "id": 1}`, etag, "rebase")
+	serve(handler, "FROBNICATE", "/game/"+gameId, "", "")
+
+	body := serve(handler, "GET", "/metrics", "", "").Body.String()
+	for _, expected := range []string{
+		`powergrim_patch_conflicts_total{status="409"}`,
+		`powergrim_patch_conflicts_total{status="412"}`,
+		`method="other"`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("metrics do not contain %s", expected)
+		}
+	}
+	if strings.Contains(body, "FROBNICATE") {
+		t.Errorf("metrics contain the non-standard method FROBNICATE")
+	}
+}
@@ -0,0 +1,26 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Preferences parses the Prefer headers of req as described in RFC 7240,
+// mapping each lower-cased preference to its value. Parameters are ignored.
+func Preferences(req *http.Request) map[string]string {
+	preferences := make(map[string]string)
+	for _, value := range req.Header.Values("Prefer") {
+		for _, preference := range strings.Split(value, ",") {
+			preference, _, _ = strings.Cut(preference, ";")
+			name, value, _ := strings.Cut(preference, "=")
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+			if _, ok := preferences[name]; !ok {
+				preferences[name] = strings.Trim(strings.TrimSpace(value), `"`)
+			}
+		}
+	}
+	return preferences
+}
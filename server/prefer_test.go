@@ -0,0 +1,19 @@
+package server_test
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	powergrim "github.com/phedny/powergrim-server/server"
+)
+
+func TestPreferences(t *testing.T) {
+	req := httptest.NewRequest("PATCH", "/game/1", nil)
+	req.Header.Add("Prefer", "Rebase, wait=10; foo=bar")
+	req.Header.Add("Prefer", `return="minimal", wait=20`)
+	expected := map[string]string{"rebase": "", "wait": "10", "return": "minimal"}
+	if got := powergrim.Preferences(req); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Preferences() returned %v; expected %v", got, expected)
+	}
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/phedny/powergrim-server/grimoire"
+)
+
+const (
+	ProblemContentType = "application/problem+json; charset=utf-8"
+	problemTypePrefix  = "urn:powergrim:problem:"
+)
+
+type Problem struct {
+	Type        string `json:"type"`
+	Title       string `json:"title"`
+	Status      int    `json:"status"`
+	Code        string `json:"code"`
+	Detail      string `json:"detail,omitempty"`
+	ActionIndex *int   `json:"actionIndex,omitempty"`
+	Field       string `json:"field,omitempty"`
+}
+
+type sentinelError struct {
+	err   error
+	name  string
+	code  string
+	field string
+}
+
+// sentinelErrors is searched in order, so that an error that wraps several
+// of them is always reported as the first one. The errors that registered
+// action types declare are searched after these.
+var sentinelErrors = []sentinelError{
+	{grimoire.ErrInvalidAlignment, "ErrInvalidAlignment", "invalid-alignment", "alignment"},
+	{grimoire.ErrInvalidReminderPosition, "ErrInvalidReminderPosition", "invalid-reminder-position", "position"},
+	{grimoire.ErrInvalidAction, "ErrInvalidAction", "invalid-action", "action"},
+	{grimoire.ErrIdLength, "ErrIdLength", "id-length", "id"},
+	{grimoire.ErrUniqueId, "ErrUniqueId", "unique-id", "id"},
+	{grimoire.ErrExistingId, "ErrExistingId", "existing-id", "id"},
+	{grimoire.ErrOptionalAfterPlayer, "ErrOptionalAfterPlayer", "optional-after-player", "afterPlayer"},
+	{grimoire.ErrRequiredAfterPlayer, "ErrRequiredAfterPlayer", "required-after-player", "afterPlayer"},
+	{grimoire.ErrDistinctIdAfterPlayer, "ErrDistinctIdAfterPlayer", "distinct-id-after-player", "afterPlayer"},
+	{grimoire.ErrMovingWithSharedReminder, "ErrMovingWithSharedReminder", "moving-with-shared-reminder", "afterPlayer"},
+	{grimoire.ErrExistingReminder, "ErrExistingReminder", "existing-reminder", "position"},
+	{grimoire.ErrReminderPosition, "ErrReminderPosition", "reminder-position", "position"},
+	{grimoire.ErrReminderExpiry, "ErrReminderExpiry", "reminder-expiry", "until"},
+	{grimoire.ErrPhase, "ErrPhase", "phase", "phase"},
+	{ErrTooManyActions, "ErrTooManyActions", "too-many-actions", ""},
+	{ErrTooManyPlayers, "ErrTooManyPlayers", "too-many-players", "players"},
+	{ErrTooManyReminders, "ErrTooManyReminders", "too-many-reminders", "reminders"},
+	{ErrUnknownLayout, "ErrUnknownLayout", "unknown-layout", "layout"},
+	{ErrSeatCount, "ErrSeatCount", "invalid-seat-count", "players"},
+	{ErrInvalidPath, "ErrInvalidPath", "invalid-seating-path", "seatingPath"},
+	{grimoire.ErrAuthorLength, "ErrAuthorLength", "author-length", "meta.author"},
+	{grimoire.ErrNoteLength, "ErrNoteLength", "note-length", "meta.note"},
+	{ErrWebhookUrl, "ErrWebhookUrl", "webhook-url", "url"},
+	{ErrWebhookSecret, "ErrWebhookSecret", "webhook-secret", "secret"},
+	{ErrTooManyWebhooks, "ErrTooManyWebhooks", "too-many-webhooks", ""},
+	{ErrQrEcc, "ErrQrEcc", "qr-ecc", "ecc"},
+	{ErrQrSeat, "ErrQrSeat", "qr-seat", "seat"},
+	{ErrQrScale, "ErrQrScale", "qr-scale", "scale"},
+	{ErrQrUrl, "ErrQrUrl", "qr-url", ""},
+	{ErrJoinCodeSpace, "ErrJoinCodeSpace", "join-code-space", ""},
+	{ErrJoinCodeExpired, "ErrJoinCodeExpired", "join-code-expired", ""},
+	{ErrJoinSeat, "ErrJoinSeat", "join-seat", "seat"},
+	{ErrIdempotencyKey, "ErrIdempotencyKey", "idempotency-key", ""},
+	{ErrIdempotencyKeyReused, "ErrIdempotencyKeyReused", "idempotency-key-reused", ""},
+	{ErrIdempotencyKeyInUse, "ErrIdempotencyKeyInUse", "idempotency-key-in-use", ""},
+}
+
+var statusCodes = map[int]string{
+	http.StatusBadRequest:            "bad-request",
+	http.StatusNotFound:              "not-found",
+	http.StatusConflict:              "conflict",
+	http.StatusPreconditionFailed:    "precondition-failed",
+	http.StatusRequestEntityTooLarge: "payload-too-large",
+	http.StatusUnsupportedMediaType:  "unsupported-media-type",
+	http.StatusUnprocessableEntity:   "unprocessable-entity",
+	http.StatusTooManyRequests:       "too-many-requests",
+	http.StatusInternalServerError:   "internal-error",
+	http.StatusNotImplemented:        "not-implemented",
+	http.StatusServiceUnavailable:    "service-unavailable",
+}
+
+func lookupSentinel(err error) (sentinelError, bool) {
+	for _, sentinel := range sentinelErrors {
+		if errors.Is(err, sentinel.err) {
+			return sentinel, true
+		}
+	}
+	for _, actionError := range grimoire.ActionErrors() {
+		if errors.Is(err, actionError.Err) {
+			return sentinelError{actionError.Err, actionError.Name, actionError.Code, actionError.Field}, true
+		}
+	}
+	return sentinelError{}, false
+}
+
+func errorName(err error) string {
+	if sentinel, ok := lookupSentinel(err); ok {
+		return sentinel.name
+	}
+	return "other"
+}
+
+func NewProblem(status int, err error) Problem {
+	problem := Problem{
+		Title:  http.StatusText(status),
+		Status: status,
+		Code:   statusCodes[status],
+	}
+	if problem.Code == "" {
+		problem.Code = "error"
+	}
+	if err != nil {
+		problem.Detail = err.Error()
+		var typeErr *json.UnmarshalTypeError
+		var syntaxErr *json.SyntaxError
+		if sentinel, ok := lookupSentinel(err); ok {
+			problem.Code = sentinel.code
+			problem.Field = sentinel.field
+		} else if errors.As(err, &typeErr) {
+			problem.Code = "invalid-type"
+			problem.Field = typeErr.Field
+		} else if errors.As(err, &syntaxErr) {
+			problem.Code = "malformed-json"
+		}
+	}
+	problem.Type = problemTypePrefix + problem.Code
+	return problem
+}
+
+// NewActionProblem describes an action in a batch that failed to decode or to
+// apply to game, pointing at the field of the action that caused it.
+func NewActionProblem(status int, err error, actionIdx int, game grimoire.Game, action any) Problem {
+	problem := NewProblem(status, err)
+	problem.ActionIndex = &actionIdx
+	if addReminder, ok := action.(grimoire.AddReminder); ok && err == grimoire.ErrReminderExpiry && addReminder.Until == "" {
+		problem.Field = "untilPhase"
+	}
+	if moveReminder, ok := action.(grimoire.MoveReminder); ok {
+		switch err {
+		case grimoire.ErrExistingReminder:
+			problem.Field = "fromPosition"
+		case grimoire.ErrReminderPosition:
+			problem.Field = "toPosition"
+			if _, err := game.CanonicalReminderPosition(moveReminder.FromPosition); err != nil {
+				problem.Field = "fromPosition"
+			}
+		}
+	}
+	return problem
+}
+
+func (problem Problem) Error() string {
+	if problem.Detail != "" {
+		return problem.Detail
+	}
+	return problem.Title
+}
+
+func writeProblem(resp http.ResponseWriter, problem Problem) {
+	header := resp.Header()
+	header.Set("Content-Type", ProblemContentType)
+	header.Set("X-Content-Type-Options", "nosniff")
+	resp.WriteHeader(problem.Status)
+	json.NewEncoder(resp).Encode(problem)
+}
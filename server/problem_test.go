@@ -0,0 +1,109 @@
+package server_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/phedny/powergrim-server/grimoire"
+	powergrim "github.com/phedny/powergrim-server/server"
+)
+
+func TestNewProblemSentinel(t *testing.T) {
+	problem := powergrim.NewProblem(http.StatusBadRequest, grimoire.ErrOptionalAfterPlayer)
+	if problem.Code != "optional-after-player" || problem.Field != "afterPlayer" {
+		t.Fatalf("NewProblem() returned code %q and field %q", problem.Code, problem.Field)
+	}
+	if problem.Type != "urn:powergrim:problem:optional-after-player" || problem.Status != http.StatusBadRequest {
+		t.Fatalf("NewProblem() returned type %q and status %d", problem.Type, problem.Status)
+	}
+
+	wrapped := fmt.Errorf("%w: unexpected end", powergrim.ErrInvalidPath)
+	if problem := powergrim.NewProblem(http.StatusInternalServerError, wrapped); problem.Code != "invalid-seating-path" {
+		t.Fatalf("NewProblem() returned code %q for a wrapped error", problem.Code)
+	}
+}
+
+var errTooFewTokens = errors.New("too few tokens")
+
+type drawToken struct {
+	Action string `json:"action"`
+}
+
+func init() {
+	grimoire.RegisterAction(grimoire.ActionType[drawToken]{
+		Name: "drawToken",
+		Apply: func(game grimoire.Game, action drawToken) (grimoire.Game, error) {
+			return grimoire.Game{}, errTooFewTokens
+		},
+		Errors: []grimoire.ActionError{{Err: errTooFewTokens, Name: "errTooFewTokens", Code: "too-few-tokens", Field: "action"}},
+	})
+}
+
+func TestNewProblemRegisteredError(t *testing.T) {
+	wrapped := fmt.Errorf("drawing: %w", errTooFewTokens)
+	if problem := powergrim.NewProblem(http.StatusBadRequest, wrapped); problem.Code != "too-few-tokens" || problem.Field != "action" {
+		t.Fatalf("NewProblem() returned code %q and field %q", problem.Code, problem.Field)
+	}
+}
+
+func TestNewProblemSeveralSentinels(t *testing.T) {
+	err := errors.Join(grimoire.ErrUniqueId, grimoire.ErrIdLength)
+	for range 10 {
+		if problem := powergrim.NewProblem(http.StatusBadRequest, err); problem.Code != "id-length" {
+			t.Fatalf("NewProblem() returned code %q; expected id-length", problem.Code)
+		}
+	}
+}
+
+func TestNewProblemFallback(t *testing.T) {
+	if problem := powergrim.NewProblem(http.StatusNotFound, nil); problem.Code != "not-found" || problem.Detail != "" {
+		t.Fatalf("NewProblem() returned code %q and detail %q", problem.Code, problem.Detail)
+	}
+	if problem := powergrim.NewProblem(http.StatusBadRequest, errors.New("other")); problem.Code != "bad-request" {
+		t.Fatalf("NewProblem() returned code %q", problem.Code)
+	}
+
+	var game grimoire.Game
+	err := json.Unmarshal([]byte(`{"players": 1}`), &game)
+	problem := powergrim.NewProblem(http.StatusBadRequest, err)
+	if problem.Code != "invalid-type" || problem.Field != "players" {
+		t.Fatalf("NewProblem() returned code %q and field %q", problem.Code, problem.Field)
+	}
+	err = json.Unmarshal([]byte(`{`), &game)
+	if problem := powergrim.NewProblem(http.StatusBadRequest, err); problem.Code != "malformed-json" {
+		t.Fatalf("NewProblem() returned code %q", problem.Code)
+	}
+}
+
+func TestNewActionProblem(t *testing.T) {
+	game := grimoire.Game{
+		Players: []grimoire.Player{{Id: 1}, {Id: 2}, {Id: 3}, {Id: 4}},
+	}
+	action := grimoire.MoveReminder{
+		FromPosition: grimoire.ReminderPosition{1, 3},
+		ToPosition:   grimoire.ReminderPosition{1},
+	}
+	problem := powergrim.NewActionProblem(http.StatusBadRequest, grimoire.ErrReminderPosition, 2, game, action)
+	if problem.ActionIndex == nil || *problem.ActionIndex != 2 || problem.Field != "fromPosition" {
+		t.Fatalf("NewActionProblem() returned %#v", problem)
+	}
+
+	action.FromPosition = grimoire.ReminderPosition{1}
+	action.ToPosition = grimoire.ReminderPosition{1, 3}
+	problem = powergrim.NewActionProblem(http.StatusBadRequest, grimoire.ErrReminderPosition, 0, game, action)
+	if problem.Field != "toPosition" {
+		t.Fatalf("NewActionProblem() returned field %q; expected toPosition", problem.Field)
+	}
+
+	data, err := json.Marshal(problem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"type":"urn:powergrim:problem:reminder-position","title":"Bad Request","status":400,"code":"reminder-position","detail":"position must be 0, player id, or array with 2 adjacent player ids","actionIndex":0,"field":"toPosition"}`
+	if string(data) != expected {
+		t.Fatalf("json.Marshal() returned %s; expected %s", data, expected)
+	}
+}
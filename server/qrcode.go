@@ -0,0 +1,637 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrQrDataTooLong = errors.New("data is too long for a QR code")
+	ErrQrEcc         = errors.New("ecc must be absent or one of L, M, Q or H")
+	ErrQrSeat        = errors.New("seat must be absent or id of existing player")
+	ErrQrScale       = errors.New("scale must be absent or between 1 and 32")
+	ErrQrUrl         = errors.New("joinUrl or publicUrl must be configured to encode join links")
+)
+
+const (
+	PngContentType = "image/png"
+	defaultQrScale = 8
+	maxQrScale     = 32
+)
+
+type QrEcc int
+
+const (
+	QrEccLow QrEcc = iota
+	QrEccMedium
+	QrEccQuartile
+	QrEccHigh
+)
+
+// ParseQrEcc parses an error correction level given as L, M, Q or H.
+func ParseQrEcc(level string) (QrEcc, bool) {
+	switch level {
+	case "L", "l":
+		return QrEccLow, true
+	case "M", "m":
+		return QrEccMedium, true
+	case "Q", "q":
+		return QrEccQuartile, true
+	case "H", "h":
+		return QrEccHigh, true
+	default:
+		return 0, false
+	}
+}
+
+func (ecc QrEcc) formatBits() int {
+	return [...]int{1, 0, 3, 2}[ecc]
+}
+
+// Tables from ISO/IEC 18004, indexed by error correction level and version.
+var qrEccCodewordsPerBlock = [4][41]int{
+	{-1, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{-1, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+	{-1, 13, 22, 18, 26, 18, 24, 18, 22, 20, 24, 28, 26, 24, 20, 30, 24, 28, 28, 26, 30, 28, 30, 30, 30, 30, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{-1, 17, 28, 22, 16, 22, 28, 26, 26, 24, 28, 24, 28, 22, 24, 24, 30, 28, 28, 26, 28, 30, 24, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+}
+
+var qrEccBlocks = [4][41]int{
+	{-1, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+	{-1, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+	{-1, 1, 1, 2, 2, 4, 4, 6, 6, 8, 8, 8, 10, 12, 16, 12, 17, 16, 18, 21, 20, 23, 23, 25, 27, 29, 34, 34, 35, 38, 40, 43, 45, 48, 51, 53, 56, 59, 62, 65, 68},
+	{-1, 1, 1, 2, 4, 4, 4, 5, 6, 8, 8, 11, 11, 16, 16, 18, 16, 19, 21, 25, 25, 25, 34, 30, 32, 35, 37, 40, 42, 45, 48, 51, 54, 57, 60, 63, 66, 70, 74, 77, 81},
+}
+
+// QrCode is a QR code symbol. Module reports whether the module at column x
+// and row y is dark.
+type QrCode struct {
+	Version int
+	Size    int
+	modules [][]bool
+	// function marks the modules of the finder, timing and alignment
+	// patterns and the format and version information.
+	function [][]bool
+}
+
+func (qr *QrCode) Module(x, y int) bool {
+	return x >= 0 && x < qr.Size && y >= 0 && y < qr.Size && qr.modules[y][x]
+}
+
+// EncodeQr encodes data in byte mode in the smallest QR code version that
+// fits it at the given error correction level, choosing the mask with the
+// lowest penalty.
+func EncodeQr(data []byte, ecc QrEcc) (*QrCode, error) {
+	version := 1
+	for ; ; version++ {
+		if version > 40 {
+			return nil, ErrQrDataTooLong
+		}
+		if 4+qrCountBits(version)+8*len(data) <= qrDataCodewords(version, ecc)*8 {
+			break
+		}
+	}
+	codewords := qrAddEccAndInterleave(qrDataBytes(data, version, ecc), version, ecc)
+
+	size := version*4 + 17
+	qr := &QrCode{Version: version, Size: size}
+	qr.modules = make([][]bool, size)
+	qr.function = make([][]bool, size)
+	for y := range qr.modules {
+		qr.modules[y] = make([]bool, size)
+		qr.function[y] = make([]bool, size)
+	}
+	qr.drawFunctionPatterns(ecc)
+	qr.drawCodewords(codewords)
+
+	bestMask, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		qr.applyMask(mask)
+		qr.drawFormatBits(ecc, mask)
+		if penalty := qr.penalty(); bestPenalty == -1 || penalty < bestPenalty {
+			bestMask, bestPenalty = mask, penalty
+		}
+		qr.applyMask(mask)
+	}
+	qr.applyMask(bestMask)
+	qr.drawFormatBits(ecc, bestMask)
+	return qr, nil
+}
+
+func qrCountBits(version int) int {
+	if version < 10 {
+		return 8
+	}
+	return 16
+}
+
+func qrRawDataModules(version int) int {
+	result := (16*version+128)*version + 64
+	if version >= 2 {
+		alignments := version/7 + 2
+		result -= (25*alignments-10)*alignments - 55
+		if version >= 7 {
+			result -= 36
+		}
+	}
+	return result
+}
+
+func qrDataCodewords(version int, ecc QrEcc) int {
+	return qrRawDataModules(version)/8 - qrEccCodewordsPerBlock[ecc][version]*qrEccBlocks[ecc][version]
+}
+
+type qrBitBuffer []bool
+
+func (bb *qrBitBuffer) append(value, bits int) {
+	for i := bits - 1; i >= 0; i-- {
+		*bb = append(*bb, value>>i&1 != 0)
+	}
+}
+
+// qrDataBytes returns the data codewords: the byte mode segment followed by
+// the terminator and padding.
+func qrDataBytes(data []byte, version int, ecc QrEcc) []byte {
+	capacity := qrDataCodewords(version, ecc) * 8
+	var bb qrBitBuffer
+	bb.append(0b0100, 4)
+	bb.append(len(data), qrCountBits(version))
+	for _, b := range data {
+		bb.append(int(b), 8)
+	}
+	bb.append(0, min(4, capacity-len(bb)))
+	bb.append(0, (8-len(bb)%8)%8)
+	for pad := 0xEC; len(bb) < capacity; pad ^= 0xEC ^ 0x11 {
+		bb.append(pad, 8)
+	}
+	result := make([]byte, len(bb)/8)
+	for i, bit := range bb {
+		if bit {
+			result[i/8] |= 1 << (7 - i%8)
+		}
+	}
+	return result
+}
+
+// qrAddEccAndInterleave splits data into blocks, appends the error correction
+// codewords to each block and interleaves the blocks.
+func qrAddEccAndInterleave(data []byte, version int, ecc QrEcc) []byte {
+	blocks := qrEccBlocks[ecc][version]
+	eccLen := qrEccCodewordsPerBlock[ecc][version]
+	rawCodewords := qrRawDataModules(version) / 8
+	shortBlocks := blocks - rawCodewords%blocks
+	shortBlockLen := rawCodewords / blocks
+
+	divisor := reedSolomonDivisor(eccLen)
+	var result []byte
+	dataBlocks := make([][]byte, blocks)
+	eccBlocks := make([][]byte, blocks)
+	k := 0
+	for i := range dataBlocks {
+		dataLen := shortBlockLen - eccLen
+		if i >= shortBlocks {
+			dataLen++
+		}
+		dataBlocks[i] = data[k : k+dataLen]
+		eccBlocks[i] = reedSolomonRemainder(dataBlocks[i], divisor)
+		k += dataLen
+	}
+	for i := 0; i <= shortBlockLen-eccLen; i++ {
+		for _, block := range dataBlocks {
+			if i < len(block) {
+				result = append(result, block[i])
+			}
+		}
+	}
+	for i := 0; i < eccLen; i++ {
+		for _, block := range eccBlocks {
+			result = append(result, block[i])
+		}
+	}
+	return result
+}
+
+func gfMultiply(x, y byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = z<<1 ^ z>>7*0x11D
+		z ^= int(y>>i&1) * int(x)
+	}
+	return byte(z)
+}
+
+// reedSolomonDivisor returns the coefficients of the generator polynomial of
+// the given degree, excluding the leading term.
+func reedSolomonDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return result
+}
+
+func reedSolomonRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, coefficient := range divisor {
+			result[i] ^= gfMultiply(coefficient, factor)
+		}
+	}
+	return result
+}
+
+func (qr *QrCode) setFunction(x, y int, dark bool) {
+	qr.modules[y][x] = dark
+	qr.function[y][x] = true
+}
+
+func (qr *QrCode) drawFunctionPatterns(ecc QrEcc) {
+	for i := 0; i < qr.Size; i++ {
+		qr.setFunction(6, i, i%2 == 0)
+		qr.setFunction(i, 6, i%2 == 0)
+	}
+	qr.drawFinderPattern(3, 3)
+	qr.drawFinderPattern(qr.Size-4, 3)
+	qr.drawFinderPattern(3, qr.Size-4)
+
+	positions := qr.alignmentPositions()
+	last := len(positions) - 1
+	for i, x := range positions {
+		for j, y := range positions {
+			if i == 0 && j == 0 || i == 0 && j == last || i == last && j == 0 {
+				continue
+			}
+			qr.drawAlignmentPattern(x, y)
+		}
+	}
+
+	qr.drawFormatBits(ecc, 0)
+	qr.drawVersion()
+}
+
+// drawFinderPattern draws a finder pattern centered at x, y, including the
+// light separator around it.
+func (qr *QrCode) drawFinderPattern(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			distance := max(abs(dx), abs(dy))
+			if xx, yy := x+dx, y+dy; xx >= 0 && xx < qr.Size && yy >= 0 && yy < qr.Size {
+				qr.setFunction(xx, yy, distance != 2 && distance != 4)
+			}
+		}
+	}
+}
+
+func (qr *QrCode) drawAlignmentPattern(x, y int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			qr.setFunction(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func (qr *QrCode) alignmentPositions() []int {
+	if qr.Version == 1 {
+		return nil
+	}
+	count := qr.Version/7 + 2
+	step := 26
+	if qr.Version != 32 {
+		step = (qr.Version*4 + count*2 + 1) / (count*2 - 2) * 2
+	}
+	result := make([]int, count)
+	result[0] = 6
+	for i, position := count-1, qr.Size-7; i >= 1; i, position = i-1, position-step {
+		result[i] = position
+	}
+	return result
+}
+
+func (qr *QrCode) drawFormatBits(ecc QrEcc, mask int) {
+	data := ecc.formatBits()<<3 | mask
+	remainder := data
+	for i := 0; i < 10; i++ {
+		remainder = remainder<<1 ^ remainder>>9*0x537
+	}
+	bits := (data<<10 | remainder) ^ 0x5412
+	bit := func(i int) bool { return bits>>i&1 != 0 }
+
+	for i := 0; i <= 5; i++ {
+		qr.setFunction(8, i, bit(i))
+	}
+	qr.setFunction(8, 7, bit(6))
+	qr.setFunction(8, 8, bit(7))
+	qr.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		qr.setFunction(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		qr.setFunction(qr.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		qr.setFunction(8, qr.Size-15+i, bit(i))
+	}
+	qr.setFunction(8, qr.Size-8, true)
+}
+
+func (qr *QrCode) drawVersion() {
+	if qr.Version < 7 {
+		return
+	}
+	remainder := qr.Version
+	for i := 0; i < 12; i++ {
+		remainder = remainder<<1 ^ remainder>>11*0x1F25
+	}
+	bits := qr.Version<<12 | remainder
+	for i := 0; i < 18; i++ {
+		dark := bits>>i&1 != 0
+		a, b := qr.Size-11+i%3, i/3
+		qr.setFunction(a, b, dark)
+		qr.setFunction(b, a, dark)
+	}
+}
+
+// drawCodewords places the codewords in the zigzag pattern of two columns
+// wide, starting at the bottom right and skipping the vertical timing pattern.
+func (qr *QrCode) drawCodewords(codewords []byte) {
+	i := 0
+	for right := qr.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vertical := 0; vertical < qr.Size; vertical++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vertical
+				if (right+1)&2 == 0 {
+					y = qr.Size - 1 - vertical
+				}
+				if !qr.function[y][x] && i < len(codewords)*8 {
+					qr.modules[y][x] = codewords[i/8]>>(7-i%8)&1 != 0
+					i++
+				}
+			}
+		}
+	}
+}
+
+func qrMask(mask, x, y int) bool {
+	switch mask {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	default:
+		return ((x+y)%2+x*y%3)%2 == 0
+	}
+}
+
+func (qr *QrCode) applyMask(mask int) {
+	for y := range qr.modules {
+		for x := range qr.modules[y] {
+			if !qr.function[y][x] && qrMask(mask, x, y) {
+				qr.modules[y][x] = !qr.modules[y][x]
+			}
+		}
+	}
+}
+
+var (
+	qrFinderLike1 = []bool{true, false, true, true, true, false, true, false, false, false, false}
+	qrFinderLike2 = []bool{false, false, false, false, true, false, true, true, true, false, true}
+)
+
+// penalty scores the symbol by the four penalty rules of the standard: runs
+// of the same colour, 2x2 blocks, finder-like patterns and dark proportion.
+func (qr *QrCode) penalty() int {
+	result := 0
+	line := make([]bool, qr.Size)
+	for _, vertical := range []bool{false, true} {
+		for i := 0; i < qr.Size; i++ {
+			for j := range line {
+				if vertical {
+					line[j] = qr.modules[j][i]
+				} else {
+					line[j] = qr.modules[i][j]
+				}
+			}
+			run := 1
+			for j := 1; j <= len(line); j++ {
+				if j < len(line) && line[j] == line[j-1] {
+					run++
+					continue
+				}
+				if run >= 5 {
+					result += run - 2
+				}
+				run = 1
+			}
+			for j := 0; j+len(qrFinderLike1) <= len(line); j++ {
+				if equalBools(line[j:j+len(qrFinderLike1)], qrFinderLike1) || equalBools(line[j:j+len(qrFinderLike2)], qrFinderLike2) {
+					result += 40
+				}
+			}
+		}
+	}
+	dark := 0
+	for y := 0; y < qr.Size; y++ {
+		for x := 0; x < qr.Size; x++ {
+			if qr.modules[y][x] {
+				dark++
+			}
+			if x+1 < qr.Size && y+1 < qr.Size {
+				color := qr.modules[y][x]
+				if qr.modules[y][x+1] == color && qr.modules[y+1][x] == color && qr.modules[y+1][x+1] == color {
+					result += 3
+				}
+			}
+		}
+	}
+	total := qr.Size * qr.Size
+	result += abs(dark*100/total-50) / 5 * 10
+	return result
+}
+
+func equalBools(a, b []bool) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+const qrQuietZone = 4
+
+// Svg renders the QR code as an SVG image with a quiet zone of four modules.
+func (qr *QrCode) Svg() []byte {
+	size := qr.Size + 2*qrQuietZone
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, size, size)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, size, size)
+	for y := 0; y < qr.Size; y++ {
+		for x := 0; x < qr.Size; x++ {
+			if qr.modules[y][x] {
+				fmt.Fprintf(&buf, "M%d,%dh1v1h-1z", x+qrQuietZone, y+qrQuietZone)
+			}
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+	return buf.Bytes()
+}
+
+// Image renders the QR code with scale pixels per module and a quiet zone of
+// four modules.
+func (qr *QrCode) Image(scale int) image.Image {
+	size := (qr.Size + 2*qrQuietZone) * scale
+	img := image.NewPaletted(image.Rect(0, 0, size, size), color.Palette{color.White, color.Black})
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			if qr.Module(x/scale-qrQuietZone, y/scale-qrQuietZone) {
+				img.SetColorIndex(x, y, 1)
+			}
+		}
+	}
+	return img
+}
+
+// joinUrl returns the URL that players open to join a game, taken from
+// config.JoinUrl or else pointing to its join code at config.PublicUrl. A seat
+// is added as query parameter.
+func joinUrl(gameId string, code JoinCode, seat string) (string, error) {
+	var u *url.URL
+	switch {
+	case config.JoinUrl != "":
+		if strings.Contains(config.JoinUrl, "{joinCode}") && !code.Valid(time.Now()) {
+			return "", ErrJoinCodeExpired
+		}
+		u, _ = url.Parse(strings.NewReplacer("{gameId}", url.PathEscape(gameId), "{joinCode}", code.Code).Replace(config.JoinUrl))
+	case config.PublicUrl != "":
+		if !code.Valid(time.Now()) {
+			return "", ErrJoinCodeExpired
+		}
+		u, _ = url.Parse(strings.TrimSuffix(config.PublicUrl, "/") + "/join/" + code.Code)
+	default:
+		return "", ErrQrUrl
+	}
+	if seat != "" {
+		query := u.Query()
+		query.Set("seat", seat)
+		u.RawQuery = query.Encode()
+	}
+	return u.String(), nil
+}
+
+// gameQrCode encodes the join URL of the game in the request, writing a
+// problem and returning false if the game or the parameters are invalid.
+func gameQrCode(resp http.ResponseWriter, req *http.Request) (*QrCode, bool) {
+	gameId := req.PathValue("gameId")
+	game, ok := games.Get(gameId)
+	if !ok {
+		writeProblem(resp, NewProblem(http.StatusNotFound, nil))
+		return nil, false
+	}
+	query := req.URL.Query()
+	ecc := QrEccMedium
+	if query.Has("ecc") {
+		if ecc, ok = ParseQrEcc(query.Get("ecc")); !ok {
+			writeProblem(resp, NewProblem(http.StatusBadRequest, ErrQrEcc))
+			return nil, false
+		}
+	}
+	seat := query.Get("seat")
+	if _, ok := parseSeat(query, game.Game); !ok {
+		writeProblem(resp, NewProblem(http.StatusBadRequest, ErrQrSeat))
+		return nil, false
+	}
+	link, err := joinUrl(gameId, game.JoinCode, seat)
+	if errors.Is(err, ErrQrUrl) {
+		writeProblem(resp, NewProblem(http.StatusNotImplemented, err))
+		return nil, false
+	} else if err != nil {
+		writeProblem(resp, NewProblem(http.StatusConflict, err))
+		return nil, false
+	}
+	// The join code and its expiry change without changing the version of
+	// the game.
+	etag := WeakETag(fmt.Sprintf("%d-%s-%d", game.Version, game.JoinCode.Code, game.JoinCode.ExpiresAt.Unix()))
+	if !checkPreconditions(resp, req, Validators{ETag: etag}) {
+		return nil, false
+	}
+	qr, err := EncodeQr([]byte(link), ecc)
+	if err != nil {
+		writeProblem(resp, NewProblem(http.StatusInternalServerError, err))
+		return nil, false
+	}
+	return qr, true
+}
+
+func gameQrSvg(resp http.ResponseWriter, req *http.Request) {
+	qr, ok := gameQrCode(resp, req)
+	if !ok {
+		return
+	}
+	resp.Header().Add("Content-Type", SvgContentType)
+	resp.Write(qr.Svg())
+}
+
+func gameQrPng(resp http.ResponseWriter, req *http.Request) {
+	scale := defaultQrScale
+	if req.URL.Query().Has("scale") {
+		var err error
+		scale, err = strconv.Atoi(req.URL.Query().Get("scale"))
+		if err != nil || scale < 1 || scale > maxQrScale {
+			writeProblem(resp, NewProblem(http.StatusBadRequest, ErrQrScale))
+			return
+		}
+	}
+	qr, ok := gameQrCode(resp, req)
+	if !ok {
+		return
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, qr.Image(scale)); err != nil {
+		writeProblem(resp, NewProblem(http.StatusInternalServerError, err))
+		return
+	}
+	resp.Header().Add("Content-Type", PngContentType)
+	resp.Write(buf.Bytes())
+}
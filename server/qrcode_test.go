@@ -0,0 +1,316 @@
+package server_test
+
+import (
+	"bytes"
+	"errors"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	powergrim "github.com/phedny/powergrim-server/server"
+)
+
+func TestEncodeQrVersion(t *testing.T) {
+	tests := []struct {
+		length  int
+		ecc     powergrim.QrEcc
+		version int
+	}{
+		{17, powergrim.QrEccLow, 1},
+		{18, powergrim.QrEccLow, 2},
+		{14, powergrim.QrEccMedium, 1},
+		{15, powergrim.QrEccMedium, 2},
+		{7, powergrim.QrEccHigh, 1},
+		{8, powergrim.QrEccHigh, 2},
+		{2953, powergrim.QrEccLow, 40},
+		{1273, powergrim.QrEccHigh, 40},
+	}
+	for _, test := range tests {
+		qr, err := powergrim.EncodeQr(bytes.Repeat([]byte{'a'}, test.length), test.ecc)
+		if err != nil {
+			t.Errorf("EncodeQr(%d bytes, %d) returned error: %s", test.length, test.ecc, err)
+			continue
+		}
+		if qr.Version != test.version || qr.Size != test.version*4+17 {
+			t.Errorf("EncodeQr(%d bytes, %d) has version %d and size %d, expected version %d", test.length, test.ecc, qr.Version, qr.Size, test.version)
+		}
+	}
+	if _, err := powergrim.EncodeQr(make([]byte, 2954), powergrim.QrEccLow); !errors.Is(err, powergrim.ErrQrDataTooLong) {
+		t.Errorf("EncodeQr(2954 bytes) returned %v, expected ErrQrDataTooLong", err)
+	}
+}
+
+func TestEncodeQrFunctionPatterns(t *testing.T) {
+	qr, err := powergrim.EncodeQr([]byte("https://example.com/game/2b1f0c0e-6f0b-4d6a-9d57-8a1c4c2e5b31"), powergrim.QrEccQuartile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, corner := range [][2]int{{0, 0}, {qr.Size - 7, 0}, {0, qr.Size - 7}} {
+		for dy := -1; dy <= 7; dy++ {
+			for dx := -1; dx <= 7; dx++ {
+				distance := max(abs(dx-3), abs(dy-3))
+				if got := qr.Module(corner[0]+dx, corner[1]+dy); got != (distance != 2 && distance != 4) {
+					t.Fatalf("finder pattern at %v has module %d,%d = %t", corner, dx, dy, got)
+				}
+			}
+		}
+	}
+	for i := 8; i < qr.Size-8; i++ {
+		if qr.Module(i, 6) != (i%2 == 0) || qr.Module(6, i) != (i%2 == 0) {
+			t.Fatalf("timing pattern is wrong at %d", i)
+		}
+	}
+	if !qr.Module(8, qr.Size-8) {
+		t.Error("dark module is missing")
+	}
+}
+
+func TestEncodeQrRoundTrip(t *testing.T) {
+	// Versions 1 and 2 have a single block, so their codewords are not
+	// interleaved.
+	eccCodewords := map[int][4]int{1: {7, 10, 13, 17}, 2: {10, 16, 22, 28}}
+	for _, data := range []string{"", "a", "https://grim.example/join", strings.Repeat("x", 30)} {
+		for ecc := powergrim.QrEccLow; ecc <= powergrim.QrEccHigh; ecc++ {
+			qr, err := powergrim.EncodeQr([]byte(data), ecc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if qr.Version > 2 {
+				continue
+			}
+			gotEcc, codewords := decodeQr(t, qr)
+			if gotEcc != ecc {
+				t.Errorf("EncodeQr(%q, %d) has format information for level %d", data, ecc, gotEcc)
+			}
+			for i := 0; i < eccCodewords[qr.Version][ecc]; i++ {
+				if syndrome := evaluateGf(codewords, gfPower(i)); syndrome != 0 {
+					t.Errorf("EncodeQr(%q, %d) has syndrome %d = %d", data, ecc, i, syndrome)
+				}
+			}
+			if got := decodeByteSegment(codewords); got != data {
+				t.Errorf("EncodeQr(%q, %d) decodes to %q", data, ecc, got)
+			}
+		}
+	}
+}
+
+func TestQrCodePng(t *testing.T) {
+	qr, err := powergrim.EncodeQr([]byte("hello"), powergrim.QrEccMedium)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, qr.Image(3)); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := (qr.Size + 8) * 3; img.Bounds().Dx() != size || img.Bounds().Dy() != size {
+		t.Errorf("Image(3) has bounds %v, expected %d pixels square", img.Bounds(), size)
+	}
+	if r, _, _, _ := img.At(4*3, 4*3).RGBA(); r != 0 {
+		t.Error("Image(3) does not have a dark module after the quiet zone")
+	}
+	if r, _, _, _ := img.At(0, 0).RGBA(); r == 0 {
+		t.Error("Image(3) does not have a light quiet zone")
+	}
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// decodeQr reads the format information and codewords of a QR code of
+// version 1 or 2.
+func decodeQr(t *testing.T, qr *powergrim.QrCode) (powergrim.QrEcc, []byte) {
+	t.Helper()
+	size := qr.Size
+	format := 0
+	positions := [][2]int{{8, 0}, {8, 1}, {8, 2}, {8, 3}, {8, 4}, {8, 5}, {8, 7}, {8, 8}, {7, 8}, {5, 8}, {4, 8}, {3, 8}, {2, 8}, {1, 8}, {0, 8}}
+	for i, p := range positions {
+		if qr.Module(p[0], p[1]) {
+			format |= 1 << i
+		}
+	}
+	ecc, mask := -1, -1
+	for data := 0; data < 32; data++ {
+		remainder := data
+		for i := 0; i < 10; i++ {
+			remainder = remainder<<1 ^ remainder>>9*0x537
+		}
+		if (data<<10|remainder)^0x5412 == format {
+			ecc, mask = []int{1, 0, 3, 2}[data>>3], data&7
+		}
+	}
+	if ecc == -1 {
+		t.Fatalf("invalid format information %015b", format)
+	}
+
+	isFunction := func(x, y int) bool {
+		switch {
+		case x == 6 || y == 6:
+			return true
+		case x <= 8 && y <= 8, x >= size-8 && y <= 8, x <= 8 && y >= size-8:
+			return true
+		case qr.Version > 1 && x >= size-9 && x <= size-5 && y >= size-9 && y <= size-5:
+			return true
+		}
+		return false
+	}
+	masks := []func(x, y int) bool{
+		func(x, y int) bool { return (x+y)%2 == 0 },
+		func(x, y int) bool { return y%2 == 0 },
+		func(x, y int) bool { return x%3 == 0 },
+		func(x, y int) bool { return (x+y)%3 == 0 },
+		func(x, y int) bool { return (x/3+y/2)%2 == 0 },
+		func(x, y int) bool { return x*y%2+x*y%3 == 0 },
+		func(x, y int) bool { return (x*y%2+x*y%3)%2 == 0 },
+		func(x, y int) bool { return ((x+y)%2+x*y%3)%2 == 0 },
+	}
+
+	var codewords []byte
+	bit := 0
+	upward := true
+	for right := size - 1; right > 0; right -= 2 {
+		if right == 6 {
+			right--
+		}
+		for i := 0; i < size; i++ {
+			y := i
+			if upward {
+				y = size - 1 - i
+			}
+			for x := right; x > right-2; x-- {
+				if isFunction(x, y) {
+					continue
+				}
+				if bit%8 == 0 {
+					codewords = append(codewords, 0)
+				}
+				if qr.Module(x, y) != masks[mask](x, y) {
+					codewords[bit/8] |= 1 << (7 - bit%8)
+				}
+				bit++
+			}
+		}
+		upward = !upward
+	}
+	return powergrim.QrEcc(ecc), codewords[:bit/8]
+}
+
+func decodeByteSegment(codewords []byte) string {
+	if len(codewords) < 2 || codewords[0]>>4 != 0b0100 {
+		return "<not byte mode>"
+	}
+	length := int(codewords[0]&0xF<<4 | codewords[1]>>4)
+	var sb strings.Builder
+	for i := 0; i < length && i+2 < len(codewords); i++ {
+		sb.WriteByte(codewords[i+1]<<4 | codewords[i+2]>>4)
+	}
+	return sb.String()
+}
+
+func gfMultiply(x, y byte) byte {
+	var z byte
+	for ; y != 0; y >>= 1 {
+		if y&1 != 0 {
+			z ^= x
+		}
+		carry := x&0x80 != 0
+		x <<= 1
+		if carry {
+			x ^= 0x1D
+		}
+	}
+	return z
+}
+
+func gfPower(n int) byte {
+	result := byte(1)
+	for i := 0; i < n; i++ {
+		result = gfMultiply(result, 2)
+	}
+	return result
+}
+
+func evaluateGf(coefficients []byte, x byte) byte {
+	var result byte
+	for _, c := range coefficients {
+		result = gfMultiply(result, x) ^ c
+	}
+	return result
+}
+
+func TestGameQrCodeHandlers(t *testing.T) {
+	config := testConfig(t)
+	config.PublicUrl = "http://a.example/"
+	handler := newTestHandler(t, config)
+	gameId := createGame(t, handler, `{"script": "tb", "players": [{"id": 3}], "reminders": []}`)
+	code := joinCode(t, handler, "GET", gameId)
+
+	tests := []struct {
+		target string
+		status int
+	}{
+		{"/game/unknown/qr.svg", http.StatusNotFound},
+		{"/game/" + gameId + "/qr.svg?ecc=X", http.StatusBadRequest},
+		{"/game/" + gameId + "/qr.svg?seat=4", http.StatusBadRequest},
+		{"/game/" + gameId + "/qr.svg?seat=first", http.StatusBadRequest},
+		{"/game/" + gameId + "/qr.png?scale=0", http.StatusBadRequest},
+		{"/game/" + gameId + "/qr.png?scale=33", http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		if resp := serve(handler, "GET", test.target, "", ""); resp.Code != test.status {
+			t.Errorf("GET %s returned %d; expected %d", test.target, resp.Code, test.status)
+		}
+	}
+
+	expected, err := powergrim.EncodeQr([]byte("http://a.example/join/"+code+"?seat=3"), powergrim.QrEccLow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := serve(handler, "GET", "/game/"+gameId+"/qr.svg?ecc=L&seat=3", "", "")
+	if resp.Code != http.StatusOK || resp.Header().Get("Content-Type") != powergrim.SvgContentType || !bytes.Equal(resp.Body.Bytes(), expected.Svg()) {
+		t.Fatalf("GET qr.svg returned %d %s", resp.Code, resp.Body)
+	}
+	var expectedPng bytes.Buffer
+	png.Encode(&expectedPng, expected.Image(2))
+	resp = serve(handler, "GET", "/game/"+gameId+"/qr.png?ecc=L&seat=3&scale=2", "", "")
+	if resp.Code != http.StatusOK || resp.Header().Get("Content-Type") != powergrim.PngContentType || !bytes.Equal(resp.Body.Bytes(), expectedPng.Bytes()) {
+		t.Fatalf("GET qr.png returned %d with a different image", resp.Code)
+	}
+
+	etag := resp.Header().Get("ETag")
+	req := httptest.NewRequest("GET", "/game/"+gameId+"/qr.png", nil)
+	req.Header.Set("If-None-Match", etag)
+	cached := httptest.NewRecorder()
+	handler.ServeHTTP(cached, req)
+	if etag == "" || cached.Code != http.StatusNotModified {
+		t.Fatalf("GET qr.png with ETag %q returned %d; expected 304", etag, cached.Code)
+	}
+	joinCode(t, handler, "POST", gameId)
+	cached = httptest.NewRecorder()
+	handler.ServeHTTP(cached, req)
+	if cached.Code != http.StatusOK || cached.Header().Get("ETag") == etag {
+		t.Fatalf("GET qr.png after regenerating the join code returned %d with ETag %q", cached.Code, cached.Header().Get("ETag"))
+	}
+}
+
+func TestGameQrCodeRequiresUrl(t *testing.T) {
+	handler := newTestHandler(t, testConfig(t))
+	gameId := createGame(t, handler, `{"script": "tb", "players": [], "reminders": []}`)
+	req := httptest.NewRequest("GET", "/game/"+gameId+"/qr.svg", nil)
+	req.Host = "attacker.example"
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	if resp.Code != http.StatusNotImplemented {
+		t.Fatalf("GET qr.svg without a configured URL returned %d; expected 501", resp.Code)
+	}
+}
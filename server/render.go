@@ -0,0 +1,123 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"math"
+	"net/http"
+
+	"github.com/phedny/powergrim-server/grimoire"
+)
+
+const (
+	SvgContentType      = "image/svg+xml; charset=utf-8"
+	defaultDimension    = 1000
+	playerTokenRatio    = 0.05
+	reminderTokenRatio  = 0.025
+	reminderTokenOffset = 2.5
+)
+
+func RenderSvg(game grimoire.Game, layout Layout) []byte {
+	width, height := layout.Dimensions[0], layout.Dimensions[1]
+	if width <= 0 || height <= 0 {
+		width, height = defaultDimension, defaultDimension
+	}
+	size := float64(min(width, height))
+	playerRadius := size * playerTokenRatio
+	reminderRadius := size * reminderTokenRatio
+	centre := point{float64(width) / 2, float64(height) / 2}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" text-anchor="middle">`, width, height, width, height)
+	if layout.BackgroundUrl != "" {
+		fmt.Fprintf(&buf, `<image href="%s" width="%d" height="%d"/>`, html.EscapeString(layout.BackgroundUrl), width, height)
+	}
+
+	positions := make(map[int]point, len(game.Players))
+	for _, player := range game.Players {
+		p := point{float64(player.Position[0]), float64(player.Position[1])}
+		positions[player.Id] = p
+		fmt.Fprintf(&buf, `<g class="player" data-id="%d">`, player.Id)
+		fmt.Fprintf(&buf, `<circle cx="%.1f" cy="%.1f" r="%.1f" fill="%s" stroke="#333" stroke-width="2"/>`, p.x, p.y, playerRadius, tokenFill(player.Alignment))
+		if !player.Alive {
+			fmt.Fprintf(&buf, `<path class="shroud" d="M%.1f,%.1f A%.1f,%.1f 0 0 1 %.1f,%.1f L%.1f,%.1f Z" fill="#222" fill-opacity="0.8"/>`,
+				p.x-playerRadius, p.y, playerRadius, playerRadius, p.x+playerRadius, p.y, p.x, p.y-playerRadius*1.4)
+		}
+		if player.Character != "" {
+			fmt.Fprintf(&buf, `<text x="%.1f" y="%.1f" font-size="%.1f">%s</text>`, p.x, p.y+playerRadius*0.15, playerRadius*0.4, html.EscapeString(player.Character))
+		}
+		fmt.Fprintf(&buf, `<text x="%.1f" y="%.1f" font-size="%.1f" font-weight="bold">%d</text>`, p.x, p.y+playerRadius*1.5, playerRadius*0.5, player.Id)
+		buf.WriteString(`</g>`)
+	}
+
+	stacked := make(map[grimoire.ReminderPosition]int)
+	for _, reminder := range game.Reminders {
+		anchor := centre
+		switch {
+		case reminder.Position[1] != 0:
+			anchor = lerp(positions[reminder.Position[0]], positions[reminder.Position[1]], 0.5)
+		case reminder.Position[0] != 0:
+			anchor = positions[reminder.Position[0]]
+		}
+		depth := stacked[reminder.Position]
+		stacked[reminder.Position]++
+		p := anchor
+		if reminder.Position[0] != 0 {
+			p = towards(anchor, centre, playerRadius+reminderRadius*(1+reminderTokenOffset*float64(depth)))
+		} else {
+			p.y += reminderRadius * reminderTokenOffset * float64(depth)
+		}
+		buf.WriteString(`<g class="reminder">`)
+		fmt.Fprintf(&buf, `<circle cx="%.1f" cy="%.1f" r="%.1f" fill="#eee" stroke="#333"/>`, p.x, p.y, reminderRadius)
+		fmt.Fprintf(&buf, `<text x="%.1f" y="%.1f" font-size="%.1f">%s</text>`, p.x, p.y+reminderRadius*0.15, reminderRadius*0.35, html.EscapeString(reminder.Token))
+		buf.WriteString(`</g>`)
+	}
+	buf.WriteString(`</svg>`)
+	return buf.Bytes()
+}
+
+func tokenFill(alignment grimoire.Alignment) string {
+	switch alignment {
+	case "good":
+		return "#cfe3ff"
+	case "evil":
+		return "#ffcfcf"
+	default:
+		return "#f4f0e6"
+	}
+}
+
+func towards(from, to point, distance float64) point {
+	length := from.distance(to)
+	if length == 0 {
+		return point{from.x, from.y + distance}
+	}
+	return lerp(from, to, math.Min(1, distance/length))
+}
+
+func renderGame(resp http.ResponseWriter, req *http.Request) {
+	game, ok := games.Get(req.PathValue("gameId"))
+	if !ok {
+		writeProblem(resp, NewProblem(http.StatusNotFound, nil))
+		return
+	}
+	var layout Layout
+	validators := game.validators()
+	if game.Game.Layout != "" {
+		// Layouts are reloaded without changing the version of the game.
+		var file VersionedFile
+		var ok bool
+		if layout, file, ok = layoutFiles.getFile(game.Game.Layout); ok {
+			validators.ETag = WeakETag(fmt.Sprintf("%d-%s", game.Version, file.hash))
+			if file.lastModified.After(validators.LastModified) {
+				validators.LastModified = file.lastModified
+			}
+		}
+	}
+	if !checkPreconditions(resp, req, validators) {
+		return
+	}
+	resp.Header().Add("Content-Type", SvgContentType)
+	resp.Write(RenderSvg(game.Game, layout))
+}
@@ -0,0 +1,83 @@
+package server_test
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/phedny/powergrim-server/grimoire"
+	powergrim "github.com/phedny/powergrim-server/server"
+)
+
+func TestRenderSvg(t *testing.T) {
+	game := grimoire.Game{
+		Players: []grimoire.Player{
+			{Id: 1, Position: [2]int{500, 100}, Character: "imp", Alignment: "evil", Alive: true},
+			{Id: 2, Position: [2]int{900, 500}, Character: "monk", Alive: false},
+		},
+		Reminders: []grimoire.Reminder{
+			{Character: "imp", Token: "Dead", Position: grimoire.ReminderPosition{2}},
+			{Character: "monk", Token: "Safe", Position: grimoire.ReminderPosition{1, 2}},
+			{Character: "drunk", Token: "Is the <Drunk>", Position: grimoire.ReminderPosition{}},
+		},
+	}
+	layout := powergrim.Layout{
+		Dimensions:    [2]int{1000, 800},
+		BackgroundUrl: "https://example.com/bg.png?a=1&b=2",
+	}
+
+	got := string(powergrim.RenderSvg(game, layout))
+	decoder := xml.NewDecoder(strings.NewReader(got))
+	for {
+		_, err := decoder.Token()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			t.Fatalf("RenderSvg() returned invalid XML: %s", err)
+		}
+	}
+	for _, expected := range []string{
+		`width="1000" height="800"`,
+		`href="https://example.com/bg.png?a=1&amp;b=2"`,
+		`<circle cx="500.0" cy="100.0" r="40.0" fill="#ffcfcf"`,
+		`class="shroud"`,
+		`>Is the &lt;Drunk&gt;</text>`,
+		`<circle cx="500.0" cy="400.0" r="20.0"`,
+	} {
+		if !strings.Contains(got, expected) {
+			t.Errorf("RenderSvg() returned %s; expected it to contain %s", got, expected)
+		}
+	}
+	if strings.Count(got, `class="shroud"`) != 1 {
+		t.Errorf("RenderSvg() returned %s; expected exactly one shroud", got)
+	}
+}
+
+func TestRenderGameETagCoversLayout(t *testing.T) {
+	const (
+		square = `{"name": "Square", "dimensions": [400, 400], "seatingPath": "M0,0 L400,0 400,400 0,400 Z"}`
+		wide   = `{"name": "Wide", "dimensions": [800, 400], "seatingPath": "M0,0 L800,0 800,400 0,400 Z"}`
+	)
+	config := testConfig(t)
+	config.ReloadInterval = powergrim.Duration(10 * time.Millisecond)
+	modTime := time.Now().Add(-time.Hour)
+	rewriteTestFile(t, config.LayoutsDir, "table.json", square, modTime)
+	handler := newTestHandler(t, config)
+	gameId := createGame(t, handler, `{"script": "tb", "layout": "table", "players": [], "reminders": []}`)
+	etag := serve(handler, "GET", "/game/"+gameId+"/render.svg", "", "").Header().Get("ETag")
+
+	rewriteTestFile(t, config.LayoutsDir, "table.json", wide, modTime.Add(time.Second))
+	waitForBody(t, handler, "/layout/table", wide)
+	req := httptest.NewRequest("GET", "/game/"+gameId+"/render.svg", nil)
+	req.Header.Set("If-None-Match", etag)
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK || resp.Header().Get("ETag") == etag {
+		t.Fatalf("GET render.svg after reloading the layout returned %d with ETag %q", resp.Code, resp.Header().Get("ETag"))
+	}
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+const (
+	scoreExactId     = 100
+	scoreExactName   = 50
+	scoreName        = 10
+	scoreCharacter   = 5
+	scoreAuthor      = 3
+	scoreTagline     = 2
+	scoreDescription = 1
+)
+
+type IndexedScript struct {
+	ScriptFileId string
+	Author       string
+	Script       Script
+}
+
+type ScriptMatch struct {
+	ScriptFileId string `json:"scriptFileId"`
+	ScriptId     string `json:"scriptId"`
+	Name         string `json:"name"`
+	Score        int    `json:"score"`
+}
+
+func SearchScripts(scripts []IndexedScript, query, level string) []ScriptMatch {
+	query = strings.TrimSpace(query)
+	terms := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	matches := make([]ScriptMatch, 0)
+	for _, indexed := range scripts {
+		script := indexed.Script
+		if level != "" && !strings.EqualFold(script.Complexity.Level, level) {
+			continue
+		}
+		// An id may contain separators that split it into several terms, so
+		// the whole query is compared with it before the terms are.
+		exactId := query != "" && strings.EqualFold(script.Id, query)
+		score, ok := scoreScript(indexed, terms)
+		if !ok && !exactId {
+			continue
+		}
+		if exactId {
+			score += scoreExactId
+		}
+		if query != "" && strings.EqualFold(script.Name, query) {
+			score += scoreExactName
+		}
+		matches = append(matches, ScriptMatch{
+			ScriptFileId: indexed.ScriptFileId,
+			ScriptId:     script.Id,
+			Name:         script.Name,
+			Score:        score,
+		})
+	}
+	slices.SortStableFunc(matches, func(a, b ScriptMatch) int {
+		return cmp.Compare(b.Score, a.Score)
+	})
+	return matches
+}
+
+func scoreScript(indexed IndexedScript, terms []string) (int, bool) {
+	script := indexed.Script
+	name := strings.ToLower(script.Name)
+	author := strings.ToLower(indexed.Author)
+	tagline := strings.ToLower(script.Tagline)
+	description := strings.ToLower(script.Description)
+	score := 0
+	for _, term := range terms {
+		termScore := 0
+		if strings.Contains(name, term) {
+			termScore += scoreName
+		}
+		if slices.ContainsFunc(script.Characters, func(character string) bool {
+			return strings.Contains(strings.ToLower(character), term)
+		}) {
+			termScore += scoreCharacter
+		}
+		if strings.Contains(author, term) {
+			termScore += scoreAuthor
+		}
+		if strings.Contains(tagline, term) {
+			termScore += scoreTagline
+		}
+		if strings.Contains(description, term) {
+			termScore += scoreDescription
+		}
+		if termScore == 0 && !strings.EqualFold(script.Id, term) {
+			return 0, false
+		}
+		score += termScore
+	}
+	return score, true
+}
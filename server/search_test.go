@@ -0,0 +1,107 @@
+package server_test
+
+import (
+	"reflect"
+	"testing"
+
+	powergrim "github.com/phedny/powergrim-server/server"
+)
+
+var searchIndex = []powergrim.IndexedScript{
+	{
+		ScriptFileId: "base",
+		Author:       "Blood on the Clocktower",
+		Script: powergrim.Script{
+			Id:          "tb",
+			Name:        "Trouble Brewing",
+			Complexity:  powergrim.ScriptComplexity{Level: "beginner"},
+			Tagline:     "Recommended for new players.",
+			Description: "Trouble Brewing has a little bit of everything.",
+			Characters:  []string{"washerwoman", "imp"},
+		},
+	},
+	{
+		ScriptFileId: "base",
+		Author:       "Blood on the Clocktower",
+		Script: powergrim.Script{
+			Id:          "bmr",
+			Name:        "Bad Moon Rising",
+			Complexity:  powergrim.ScriptComplexity{Level: "intermediate"},
+			Tagline:     "Recommended for players who have played Trouble Brewing.",
+			Description: "Bad Moon Rising is a mix of trouble and treachery.",
+			Characters:  []string{"grandmother", "zombuul"},
+		},
+	},
+	{
+		ScriptFileId: "homebrew",
+		Author:       "Somebody",
+		Script: powergrim.Script{
+			Id:         "imps",
+			Name:       "Imps Galore",
+			Characters: []string{"imp", "washerwoman"},
+		},
+	},
+	{
+		ScriptFileId: "homebrew",
+		Author:       "Somebody",
+		Script: powergrim.Script{
+			Id:         "tb-2",
+			Name:       "Sequel",
+			Characters: []string{"clockmaker"},
+		},
+	},
+}
+
+func TestSearchScriptsRanking(t *testing.T) {
+	got := powergrim.SearchScripts(searchIndex, "trouble", "")
+	expected := []powergrim.ScriptMatch{
+		{ScriptFileId: "base", ScriptId: "tb", Name: "Trouble Brewing", Score: 11},
+		{ScriptFileId: "base", ScriptId: "bmr", Name: "Bad Moon Rising", Score: 3},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("SearchScripts() returned %#v; expected %#v", got, expected)
+	}
+}
+
+func TestSearchScriptsAllTermsMustMatch(t *testing.T) {
+	got := powergrim.SearchScripts(searchIndex, "imp washerwoman", "")
+	expected := []powergrim.ScriptMatch{
+		{ScriptFileId: "homebrew", ScriptId: "imps", Name: "Imps Galore", Score: 20},
+		{ScriptFileId: "base", ScriptId: "tb", Name: "Trouble Brewing", Score: 10},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("SearchScripts() returned %#v; expected %#v", got, expected)
+	}
+}
+
+func TestSearchScriptsExactId(t *testing.T) {
+	expected := []powergrim.ScriptMatch{
+		{ScriptFileId: "base", ScriptId: "bmr", Name: "Bad Moon Rising", Score: 100},
+	}
+	for _, query := range []string{"bmr", "BMR"} {
+		if got := powergrim.SearchScripts(searchIndex, query, ""); !reflect.DeepEqual(got, expected) {
+			t.Fatalf("SearchScripts(%q) returned %#v; expected %#v", query, got, expected)
+		}
+	}
+}
+
+func TestSearchScriptsExactIdWithSeparators(t *testing.T) {
+	expected := []powergrim.ScriptMatch{
+		{ScriptFileId: "homebrew", ScriptId: "tb-2", Name: "Sequel", Score: 100},
+	}
+	for _, query := range []string{"tb-2", " TB-2 "} {
+		if got := powergrim.SearchScripts(searchIndex, query, ""); !reflect.DeepEqual(got, expected) {
+			t.Fatalf("SearchScripts(%q) returned %#v; expected %#v", query, got, expected)
+		}
+	}
+}
+
+func TestSearchScriptsLevel(t *testing.T) {
+	got := powergrim.SearchScripts(searchIndex, "", "Beginner")
+	expected := []powergrim.ScriptMatch{
+		{ScriptFileId: "base", ScriptId: "tb", Name: "Trouble Brewing", Score: 0},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("SearchScripts() returned %#v; expected %#v", got, expected)
+	}
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/phedny/powergrim-server/grimoire"
+)
+
+var (
+	ErrUnknownLayout = errors.New("layout must be id of existing layout")
+	ErrSeatCount     = errors.New("players must be a number between 0 and the maximum number of players")
+)
+
+// maxSeatCount bounds the number of seats computed for a layout when the
+// number of players in a game is unlimited.
+const maxSeatCount = 1000
+
+func PlacePlayers(game, previous grimoire.Game, layout Layout) (grimoire.Game, error) {
+	if layout.SeatingPath == "" {
+		var players []grimoire.Player
+		for playerIdx, player := range game.Players {
+			if slices.ContainsFunc(previous.Players, grimoire.PlayerWithId(player.Id)) {
+				continue
+			}
+			if players == nil {
+				players = slices.Clone(game.Players)
+			}
+			players[playerIdx].Position = layout.NewPlayerToken
+		}
+		if players != nil {
+			game.Players = players
+		}
+		return game, nil
+	}
+	if slices.EqualFunc(game.Players, previous.Players, func(p1, p2 grimoire.Player) bool { return p1.Id == p2.Id }) {
+		return game, nil
+	}
+	seats, err := SeatPositions(layout.SeatingPath, len(game.Players))
+	if err != nil {
+		return grimoire.Game{}, err
+	}
+	game.Players = slices.Clone(game.Players)
+	for playerIdx := range game.Players {
+		game.Players[playerIdx].Position = seats[playerIdx]
+	}
+	return game, nil
+}
+
+func validateLayouts(layouts map[string]Layout) error {
+	for layoutId, layout := range layouts {
+		if layout.SeatingPath == "" {
+			continue
+		}
+		if _, err := parseSvgPath(layout.SeatingPath); err != nil {
+			return fmt.Errorf("layout %q: %w", layoutId, err)
+		}
+	}
+	return nil
+}
+
+func placePlayers(game, previous grimoire.Game) (grimoire.Game, error) {
+	if game.Layout == "" {
+		return game, nil
+	}
+	layout, ok := layoutFiles.get(game.Layout)
+	if !ok {
+		return game, nil
+	}
+	return PlacePlayers(game, previous, layout)
+}
+
+// placeNewPlayers places the players of a new game, keeping the position of
+// players that already have one.
+func placeNewPlayers(game grimoire.Game) (grimoire.Game, error) {
+	placed, err := placePlayers(game, grimoire.Game{})
+	if err != nil {
+		return grimoire.Game{}, err
+	}
+	for playerIdx, player := range game.Players {
+		if player.Position != [2]int{} {
+			placed.Players[playerIdx].Position = player.Position
+		}
+	}
+	return placed, nil
+}
+
+func layoutSeats(resp http.ResponseWriter, req *http.Request) {
+	layout, ok := layoutFiles.get(req.PathValue("id"))
+	if !ok {
+		writeProblem(resp, NewProblem(http.StatusNotFound, nil))
+		return
+	}
+	maxSeats := config.MaxPlayers
+	if maxSeats == 0 {
+		maxSeats = maxSeatCount
+	}
+	players, err := strconv.Atoi(req.URL.Query().Get("players"))
+	if err != nil || players < 0 || players > maxSeats {
+		writeProblem(resp, NewProblem(http.StatusBadRequest, ErrSeatCount))
+		return
+	}
+	var seats [][2]int
+	if layout.SeatingPath != "" {
+		seats, err = SeatPositions(layout.SeatingPath, players)
+		if err != nil {
+			writeProblem(resp, NewProblem(http.StatusInternalServerError, err))
+			return
+		}
+	}
+	header := resp.Header()
+	header.Add("Content-Type", JsonContentType)
+	json.NewEncoder(resp).Encode(seats)
+}
@@ -0,0 +1,119 @@
+package server_test
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/phedny/powergrim-server/grimoire"
+	powergrim "github.com/phedny/powergrim-server/server"
+)
+
+func TestPlacePlayersAlongSeatingPath(t *testing.T) {
+	layout := powergrim.Layout{
+		SeatingPath: "M0,0 L400,0 400,400 0,400 Z",
+	}
+	previous := grimoire.Game{
+		Players: []grimoire.Player{
+			{Id: 1, Position: [2]int{0, 0}},
+			{Id: 2, Position: [2]int{400, 400}},
+		},
+	}
+	game := grimoire.Game{
+		Players: []grimoire.Player{
+			{Id: 1, Position: [2]int{0, 0}},
+			{Id: 3},
+			{Id: 2, Position: [2]int{400, 400}},
+			{Id: 4},
+		},
+	}
+
+	got, err := powergrim.PlacePlayers(game, previous, layout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := grimoire.Game{
+		Players: []grimoire.Player{
+			{Id: 1, Position: [2]int{0, 0}},
+			{Id: 3, Position: [2]int{400, 0}},
+			{Id: 2, Position: [2]int{400, 400}},
+			{Id: 4, Position: [2]int{0, 400}},
+		},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("PlacePlayers() returned %#v; expected %#v", got, expected)
+	}
+	if game.Players[1].Position != [2]int{} {
+		t.Fatalf("PlacePlayers() modified its receiver")
+	}
+}
+
+func TestPlacePlayersWithoutSeatingPath(t *testing.T) {
+	layout := powergrim.Layout{
+		NewPlayerToken: [2]int{500, 500},
+	}
+	previous := grimoire.Game{
+		Players: []grimoire.Player{
+			{Id: 1, Position: [2]int{10, 20}},
+		},
+	}
+	game := grimoire.Game{
+		Players: []grimoire.Player{
+			{Id: 1, Position: [2]int{10, 20}},
+			{Id: 2},
+		},
+	}
+
+	got, err := powergrim.PlacePlayers(game, previous, layout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := grimoire.Game{
+		Players: []grimoire.Player{
+			{Id: 1, Position: [2]int{10, 20}},
+			{Id: 2, Position: [2]int{500, 500}},
+		},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("PlacePlayers() returned %#v; expected %#v", got, expected)
+	}
+}
+
+func TestLayoutSeatsBound(t *testing.T) {
+	config := testConfig(t)
+	config.MaxPlayers = 20
+	writeTestFile(t, config.LayoutsDir, "circle.json", `{"name": "Circle", "dimensions": [400, 400], "seatingPath": "M0,0 L400,0 400,400 0,400 Z"}`)
+	handler := newTestHandler(t, config)
+
+	tests := []struct {
+		players string
+		status  int
+	}{
+		{"0", http.StatusOK},
+		{"20", http.StatusOK},
+		{"21", http.StatusBadRequest},
+		{"1000000000", http.StatusBadRequest},
+		{"-1", http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		resp := serve(handler, "GET", "/layout/circle/seats?players="+test.players, "", "")
+		if resp.Code != test.status {
+			t.Errorf("GET seats for %s players returned %d; expected %d", test.players, resp.Code, test.status)
+		}
+	}
+}
+
+func TestNewGameKeepsPositions(t *testing.T) {
+	config := testConfig(t)
+	writeTestFile(t, config.LayoutsDir, "square.json", `{"name": "Square", "dimensions": [400, 400], "seatingPath": "M0,0 L400,0 400,400 0,400 Z"}`)
+	handler := newTestHandler(t, config)
+
+	gameId := createGame(t, handler, `{"script": "tb", "layout": "square", "players": [{"id": 1, "position": [10, 20]}, {"id": 2}], "reminders": []}`)
+	game := fetchGame(t, handler, gameId)
+	if game.Players[0].Position != [2]int{10, 20} {
+		t.Errorf("player with a position was moved to %v", game.Players[0].Position)
+	}
+	if game.Players[1].Position != [2]int{400, 400} {
+		t.Errorf("player without a position was placed at %v; expected [400 400]", game.Players[1].Position)
+	}
+}
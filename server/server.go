@@ -0,0 +1,497 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/phedny/powergrim-server/grimoire"
+)
+
+const (
+	JsonContentType       = "application/json; charset=utf-8"
+	ScriptfileContentType = "application/prs.powergrim.scriptfile+json; charset=utf-8"
+	LayoutContentType     = "application/prs.powergrim.layout+json; charset=utf-0"
+	GameContentType       = "application/prs.powergrim.game+json; charset=utf-8"
+	ActionContentType     = "application/prs.powergrim.action+json; charset=utf-8"
+	ActionsContentType    = "application/prs.powergrim.actions+json; charset=utf-8"
+)
+
+type VersionedGame struct {
+	LastModified time.Time
+	Version      int
+	Game         grimoire.Game
+	History      []HistoryEntry
+	JoinCode     JoinCode
+	Webhooks     []WebhookSubscription
+}
+
+var config Config
+var store gameStore
+var scriptIdsMut sync.RWMutex
+var scriptIdToScriptFileId = make(map[string]string)
+var indexedScripts []IndexedScript
+var layoutFiles *fileSet[Layout]
+var games *GameRegistry
+
+// Main runs the server with the configuration from the command line and the
+// environment. A binary that imports packages registering extra actions with
+// grimoire.RegisterAction can call Main to serve them.
+func Main() {
+	var err error
+	config, err = LoadConfig(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	logger, err := NewLogger(os.Stderr, config.LogLevel, config.LogFormat)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	slog.SetDefault(logger)
+	// The effective configuration is logged whatever the log level, so that it
+	// can be checked when only warnings and errors are logged.
+	configLogger, _ := NewLogger(os.Stderr, "info", config.LogFormat)
+	configLogger.Info("effective configuration", "config", config)
+
+	handler, err := NewHandler(context.Background(), config)
+	if err != nil {
+		slog.Error("failed to start server", "error", err)
+		os.Exit(1)
+	}
+	server := &http.Server{
+		Addr:         config.Listen,
+		Handler:      handler,
+		ReadTimeout:  time.Duration(config.ReadTimeout),
+		WriteTimeout: time.Duration(config.WriteTimeout),
+		IdleTimeout:  time.Duration(config.IdleTimeout),
+	}
+	err = serveUntilSignalled(server, time.Duration(config.ShutdownTimeout))
+	if errors.Is(err, http.ErrServerClosed) {
+		slog.Info("server closed")
+	} else if err != nil {
+		slog.Error("error running server", "error", err)
+		os.Exit(1)
+	}
+}
+
+// NewHandler sets up the games, files and background workers of the server
+// from cfg and returns the handler serving its routes. Script and layout files
+// are reloaded when they change until ctx is done.
+func NewHandler(ctx context.Context, cfg Config) (http.Handler, error) {
+	config = cfg
+	mux := http.NewServeMux()
+	clientLimiter = NewRateLimiter(config.ClientRateLimit, config.ClientRateBurst)
+	gameLimiter = NewRateLimiter(config.GameRateLimit, config.GameRateBurst)
+
+	var err error
+	store, err = newGameStore(config)
+	if err != nil {
+		return nil, err
+	}
+	loaded, err := store.load()
+	if err != nil {
+		return nil, err
+	}
+	games = NewGameRegistry(loaded, saveGame)
+	joinCodes = NewJoinCodeRegistry(time.Duration(config.JoinCodeTtl), rand.Reader)
+	for gameId, game := range loaded {
+		joinCodes.Restore(gameId, game.JoinCode, time.Now())
+	}
+
+	mux.HandleFunc("GET /findScript", findScript)
+	mux.HandleFunc("GET /searchScripts", searchScripts)
+	scripts, err := handleFiles[ScriptFile](mux, config.ScriptsDir, "script", ScriptfileContentType, collectScriptIds)
+	if err != nil {
+		return nil, err
+	}
+	mux.HandleFunc("POST /script", scripts.uploadFile)
+	go scripts.watch(ctx, time.Duration(config.ReloadInterval))
+
+	layoutFiles, err = handleFiles[Layout](mux, config.LayoutsDir, "layout", LayoutContentType, validateLayouts)
+	if err != nil {
+		return nil, err
+	}
+	mux.HandleFunc("GET /layout/{id}/seats", layoutSeats)
+	go layoutFiles.watch(ctx, time.Duration(config.ReloadInterval))
+
+	idempotencyCache = NewIdempotencyCache(time.Duration(config.IdempotencyWindow), config.MaxIdempotencyKeys)
+	mux.Handle("POST /game", idempotencyCache.Handler(http.HandlerFunc(newGame)))
+	mux.HandleFunc("GET /game/{gameId}", getGame)
+	mux.Handle("PATCH /game/{gameId}", idempotencyCache.Handler(http.HandlerFunc(patchGame)))
+	mux.HandleFunc("GET /game/{gameId}/history", gameHistory)
+	denied, err := parseNetworks(config.WebhookDenyNetworks)
+	if err != nil {
+		return nil, err
+	}
+	webhooks = NewWebhookDispatcher(NewWebhookClient(webhookTimeout, denied), config.WebhookAttempts, time.Duration(config.WebhookBackoff), config.WebhookDisableAfter)
+	webhooks.onDisable = saveWebhook
+	for gameId, game := range loaded {
+		webhooks.Restore(gameId, game.Webhooks)
+	}
+	mux.HandleFunc("POST /game/{gameId}/webhooks", createWebhook)
+	mux.HandleFunc("GET /game/{gameId}/webhooks", listWebhooks)
+	mux.HandleFunc("GET /game/{gameId}/webhooks/{webhookId}", getWebhook)
+	mux.HandleFunc("DELETE /game/{gameId}/webhooks/{webhookId}", deleteWebhook)
+	mux.HandleFunc("POST /game/{gameId}/webhooks/{webhookId}/enable", enableWebhook)
+	mux.HandleFunc("GET /game/{gameId}/render.svg", renderGame)
+	mux.HandleFunc("GET /game/{gameId}/qr.svg", gameQrSvg)
+	mux.HandleFunc("GET /game/{gameId}/qr.png", gameQrPng)
+	mux.HandleFunc("GET /game/{gameId}/joinCode", getJoinCode)
+	mux.HandleFunc("POST /game/{gameId}/joinCode", regenerateJoinCode)
+	mux.HandleFunc("GET /join/{code}", joinGame)
+	mux.HandleFunc("GET /metrics", serveMetrics)
+
+	return logRequests(mux, measureRequests(mux, CorsHandler(config.AllowedOrigins, limitClientRate(limitBodySize(mux))))), nil
+}
+
+func (game VersionedGame) validators() Validators {
+	return Validators{
+		ETag:         gameETag(game.Version),
+		LastModified: game.LastModified,
+		WeakIfMatch:  true,
+	}
+}
+
+func newGame(resp http.ResponseWriter, req *http.Request) {
+	contentType := req.Header["Content-Type"]
+	if len(contentType) != 1 || contentType[0] != GameContentType {
+		writeProblem(resp, NewProblem(http.StatusUnsupportedMediaType, nil))
+		return
+	}
+	game := VersionedGame{
+		LastModified: time.Now().Truncate(time.Second),
+		Version:      1,
+	}
+	err := json.NewDecoder(req.Body).Decode(&game.Game)
+	if err != nil {
+		decodeFailed(resp, err)
+		return
+	}
+	if err := checkGameLimits(game.Game); err != nil {
+		writeProblem(resp, NewProblem(http.StatusBadRequest, err))
+		return
+	}
+	if err := game.Game.ValidatePhase(); err != nil {
+		writeProblem(resp, NewProblem(http.StatusBadRequest, err))
+		return
+	}
+	if game.Game.Layout != "" {
+		if _, ok := layoutFiles.get(game.Game.Layout); !ok {
+			writeProblem(resp, NewProblem(http.StatusBadRequest, ErrUnknownLayout))
+			return
+		}
+	}
+	game.Game, err = placeNewPlayers(game.Game)
+	if err != nil {
+		writeProblem(resp, NewProblem(http.StatusInternalServerError, err))
+		return
+	}
+	gameId := uuid.NewString()
+	game.JoinCode, err = joinCodes.Allocate(gameId, time.Now())
+	if err != nil {
+		writeProblem(resp, NewProblem(http.StatusServiceUnavailable, err))
+		return
+	}
+	games.Add(gameId, game)
+	resp.Header().Add("Location", fmt.Sprintf("/game/%s", gameId))
+	resp.WriteHeader(http.StatusCreated)
+}
+
+func getGame(resp http.ResponseWriter, req *http.Request) {
+	gameId := req.PathValue("gameId")
+	wait, long := preferredWait(req)
+	game, changed, ok := games.Watch(gameId)
+	if !ok {
+		writeProblem(resp, NewProblem(http.StatusNotFound, nil))
+		return
+	}
+	if long && EvaluatePreconditions(req, game.validators()) == http.StatusNotModified {
+		resp.Header().Set("Preference-Applied", fmt.Sprintf("wait=%d", int(wait/time.Second)))
+		deadline := time.Now().Add(wait)
+		if config.WriteTimeout > 0 {
+			http.NewResponseController(resp).SetWriteDeadline(deadline.Add(time.Duration(config.WriteTimeout)))
+		}
+		for {
+			woken, answer := waitForChange(req.Context(), changed, deadline)
+			if !answer {
+				return
+			}
+			game, changed, _ = games.Watch(gameId)
+			if !woken || EvaluatePreconditions(req, game.validators()) != http.StatusNotModified {
+				break
+			}
+		}
+	}
+	writeGame(resp, req, game)
+}
+
+func writeGame(resp http.ResponseWriter, req *http.Request, game VersionedGame) {
+	resp.Header().Add("Vary", "Accept-Encoding")
+	if !checkPreconditions(resp, req, game.validators()) {
+		return
+	}
+	data, err := json.Marshal(game.Game)
+	if err != nil {
+		writeProblem(resp, NewProblem(http.StatusInternalServerError, err))
+		return
+	}
+	resp.Header().Add("Content-Type", GameContentType)
+	writeEncoded(resp, req, append(data, '\n'))
+}
+
+// patchGame applies actions to the latest version of a game. Clients that
+// only want them applied to the version they have seen send If-Match, which is
+// checked again while holding the lock of the game. With Prefer: rebase, a
+// batch for an older version is applied to the latest version instead, and
+// only an action that fails against it is reported as a conflict.
+func patchGame(resp http.ResponseWriter, req *http.Request) {
+	gameId := req.PathValue("gameId")
+	game, ok := games.Get(gameId)
+	if !ok {
+		writeProblem(resp, NewProblem(http.StatusNotFound, nil))
+		return
+	}
+	if ok, retryAfter := gameLimiter.Allow(gameId, time.Now()); !ok {
+		tooManyRequests(resp, retryAfter)
+		return
+	}
+	_, rebase := Preferences(req)["rebase"]
+	if status, _ := patchPreconditions(req, game, rebase); status != 0 {
+		if status == http.StatusPreconditionFailed {
+			patchConflicts.Inc(strconv.Itoa(status))
+		}
+		writeProblem(resp, NewProblem(status, nil))
+		return
+	}
+	contentType := req.Header["Content-Type"]
+	if len(contentType) != 1 {
+		writeProblem(resp, NewProblem(http.StatusUnsupportedMediaType, nil))
+		return
+	}
+	var actions []grimoire.WrappedAction
+	switch contentType[0] {
+	case ActionContentType:
+		actions = make([]grimoire.WrappedAction, 1)
+		err := json.NewDecoder(req.Body).Decode(&actions[0])
+		if err != nil {
+			decodeFailed(resp, err)
+			return
+		}
+	case ActionsContentType:
+		var rawActions []json.RawMessage
+		err := json.NewDecoder(req.Body).Decode(&rawActions)
+		if err != nil {
+			decodeFailed(resp, err)
+			return
+		}
+		if config.MaxActionsPerBatch > 0 && len(rawActions) > config.MaxActionsPerBatch {
+			writeProblem(resp, NewProblem(http.StatusRequestEntityTooLarge, ErrTooManyActions))
+			return
+		}
+		actions = make([]grimoire.WrappedAction, len(rawActions))
+		for i, rawAction := range rawActions {
+			if err := json.Unmarshal(rawAction, &actions[i]); err != nil {
+				writeProblem(resp, NewActionProblem(http.StatusBadRequest, err, i, game.Game, nil))
+				return
+			}
+		}
+	default:
+		writeProblem(resp, NewProblem(http.StatusUnsupportedMediaType, nil))
+		return
+	}
+	var expired []grimoire.Reminder
+	var entries []HistoryEntry
+	updated, _, err := games.Update(gameId, func(game VersionedGame) (VersionedGame, error) {
+		status, rebased := patchPreconditions(req, game, rebase)
+		if status != 0 {
+			return game, NewProblem(status, nil)
+		}
+		failedStatus := http.StatusBadRequest
+		if rebased {
+			failedStatus = http.StatusConflict
+		}
+		var newGame grimoire.Game
+		var err error
+		newGame, expired, err = applyActions(game.Game, actions, failedStatus)
+		if err != nil {
+			return game, err
+		}
+		now := time.Now()
+		entries, err = historyEntries(game.Version+1, now, actions)
+		if err != nil {
+			return game, NewProblem(http.StatusInternalServerError, err)
+		}
+		updated := VersionedGame{
+			LastModified: now.Truncate(time.Second),
+			Version:      game.Version + 1,
+			Game:         newGame,
+			History:      appendHistory(game.History, entries),
+			JoinCode:     game.JoinCode,
+			Webhooks:     game.Webhooks,
+		}
+		// Events are queued while holding the lock of the game, so that
+		// webhooks receive them in the order of their versions.
+		webhooks.Notify(WebhookEvent{
+			Event:            "actions.applied",
+			GameId:           gameId,
+			Version:          updated.Version,
+			Actions:          entries,
+			ExpiredReminders: expired,
+			Game:             updated.Game,
+		})
+		return updated, nil
+	})
+	var problem Problem
+	if errors.As(err, &problem) {
+		if problem.Status == http.StatusPreconditionFailed || problem.Status == http.StatusConflict {
+			patchConflicts.Inc(strconv.Itoa(problem.Status))
+		}
+		writeProblem(resp, problem)
+		return
+	}
+	for _, action := range actions {
+		actionsApplied.Inc(grimoire.ActionName(action.Action))
+	}
+	auditActions(req.Context(), gameId, updated.Version-1, updated.Version, actions)
+	header := resp.Header()
+	header.Add("Content-Type", GameContentType)
+	if rebase {
+		header.Set("Preference-Applied", "rebase")
+	}
+	updated.validators().writeHeaders(header)
+	json.NewEncoder(resp).Encode(patchedGame{updated.Game, expired})
+}
+
+// patchedGame is the response to a PATCH request, listing the reminders that
+// expired because the phase advanced next to the game.
+type patchedGame struct {
+	grimoire.Game
+	ExpiredReminders []grimoire.Reminder `json:"expiredReminders,omitempty"`
+}
+
+// applyActions applies the actions to game in order, followed by the game
+// limits and seating. It returns the reminders that expired. A failing action
+// results in a Problem.
+// patchPreconditions evaluates the preconditions of a PATCH request against
+// game. With rebase, If-Match and If-Unmodified-Since only name the version the
+// actions were based on, so they do not fail the request; rebased then reports
+// whether the game has changed since that version.
+func patchPreconditions(req *http.Request, game VersionedGame, rebase bool) (status int, rebased bool) {
+	status = EvaluatePreconditions(req, game.validators())
+	if status != http.StatusPreconditionFailed || !rebase {
+		return status, false
+	}
+	latest := req.Clone(req.Context())
+	latest.Header.Del("If-Match")
+	latest.Header.Del("If-Unmodified-Since")
+	status = EvaluatePreconditions(latest, game.validators())
+	return status, status == 0
+}
+
+// applyActions applies actions to game, reporting an action that fails with
+// failedStatus.
+func applyActions(game grimoire.Game, actions []grimoire.WrappedAction, failedStatus int) (grimoire.Game, []grimoire.Reminder, error) {
+	previous := game
+	var expired []grimoire.Reminder
+	for i, action := range actions {
+		newGame, err := game.ApplyAction(action.Action)
+		if err != nil {
+			actionErrors.Inc(errorName(err))
+			return grimoire.Game{}, nil, NewActionProblem(failedStatus, err, i, game, action.Action)
+		}
+		expired = append(expired, game.ExpiredReminders(newGame)...)
+		game = newGame
+	}
+	if err := checkGameLimits(game); err != nil {
+		actionErrors.Inc(errorName(err))
+		return grimoire.Game{}, nil, NewProblem(failedStatus, err)
+	}
+	game, err := placePlayers(game, previous)
+	if err != nil {
+		return grimoire.Game{}, nil, NewProblem(http.StatusInternalServerError, err)
+	}
+	return game, expired, nil
+}
+
+func saveGame(gameId string, game VersionedGame) {
+	if err := store.save(gameId, game); err != nil {
+		slog.Error("error saving game", "game_id", gameId, "error", err)
+	}
+}
+
+func collectScriptIds(scriptFiles map[string]ScriptFile) error {
+	scriptFileIds := make([]string, 0, len(scriptFiles))
+	for scriptFileId := range scriptFiles {
+		scriptFileIds = append(scriptFileIds, scriptFileId)
+	}
+	slices.Sort(scriptFileIds)
+	index := make(map[string]string)
+	var indexed []IndexedScript
+	for _, scriptFileId := range scriptFileIds {
+		scriptFile := scriptFiles[scriptFileId]
+		for _, script := range scriptFile.Scripts {
+			key := strings.ToLower(script.Id)
+			if index[key] != "" {
+				return fmt.Errorf("duplicate script id %q", script.Id)
+			}
+			index[key] = scriptFileId
+			indexed = append(indexed, IndexedScript{
+				ScriptFileId: scriptFileId,
+				Author:       scriptFile.Author,
+				Script:       script,
+			})
+		}
+	}
+	scriptIdsMut.Lock()
+	scriptIdToScriptFileId = index
+	indexedScripts = indexed
+	scriptIdsMut.Unlock()
+	return nil
+}
+
+func findScript(resp http.ResponseWriter, req *http.Request) {
+	if !req.URL.Query().Has("q") {
+		writeProblem(resp, NewProblem(http.StatusBadRequest, nil))
+		return
+	}
+	scriptIdsMut.RLock()
+	scriptFileId := scriptIdToScriptFileId[strings.ToLower(req.URL.Query().Get("q"))]
+	scriptIdsMut.RUnlock()
+	header := resp.Header()
+	header.Add("Content-Type", JsonContentType)
+	if scriptFileId == "" {
+		json.NewEncoder(resp).Encode(nil)
+	} else {
+		json.NewEncoder(resp).Encode(scriptFileId)
+	}
+}
+
+func searchScripts(resp http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	if !query.Has("q") && !query.Has("level") {
+		writeProblem(resp, NewProblem(http.StatusBadRequest, nil))
+		return
+	}
+	scriptIdsMut.RLock()
+	matches := SearchScripts(indexedScripts, query.Get("q"), query.Get("level"))
+	scriptIdsMut.RUnlock()
+	header := resp.Header()
+	header.Add("Content-Type", JsonContentType)
+	json.NewEncoder(resp).Encode(matches)
+}
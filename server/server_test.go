@@ -0,0 +1,146 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/phedny/powergrim-server/grimoire"
+	powergrim "github.com/phedny/powergrim-server/server"
+)
+
+func testConfig(t *testing.T) powergrim.Config {
+	config := powergrim.DefaultConfig()
+	config.ScriptsDir = t.TempDir()
+	config.LayoutsDir = t.TempDir()
+	config.ClientRateLimit = 1000
+	config.ClientRateBurst = 1000
+	config.GameRateLimit = 1000
+	config.GameRateBurst = 1000
+	config.ReloadInterval = powergrim.Duration(time.Hour)
+	return config
+}
+
+func newTestHandler(t *testing.T, config powergrim.Config) http.Handler {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	handler, err := powergrim.NewHandler(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return handler
+}
+
+func writeTestFile(t *testing.T, dirName, fileName, content string) {
+	if err := os.WriteFile(path.Join(dirName, fileName), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func serve(handler http.Handler, method, target, contentType, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	return resp
+}
+
+func createGame(t *testing.T, handler http.Handler, body string) string {
+	resp := serve(handler, "POST", "/game", powergrim.GameContentType, body)
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("POST /game returned %d %s", resp.Code, resp.Body)
+	}
+	return strings.TrimPrefix(resp.Header().Get("Location"), "/game/")
+}
+
+func fetchGame(t *testing.T, handler http.Handler, gameId string) grimoire.Game {
+	resp := serve(handler, "GET", "/game/"+gameId, "", "")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("GET /game/%s returned %d %s", gameId, resp.Code, resp.Body)
+	}
+	var game grimoire.Game
+	if err := json.NewDecoder(resp.Body).Decode(&game); err != nil {
+		t.Fatal(err)
+	}
+	return game
+}
+
+func patchAction(handler http.Handler, gameId, body, ifMatch, prefer string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("PATCH", "/game/"+gameId, strings.NewReader(body))
+	req.Header.Set("Content-Type", powergrim.ActionContentType)
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	if prefer != "" {
+		req.Header.Set("Prefer", prefer)
+	}
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	return resp
+}
+
+func TestPatchGamePreconditions(t *testing.T) {
+	handler := newTestHandler(t, testConfig(t))
+	gameId := createGame(t, handler, `{"script": "tb", "players": [], "reminders": []}`)
+	etag := serve(handler, "GET", "/game/"+gameId, "", "").Header().Get("ETag")
+
+	resp := patchAction(handler, gameId, `{"action": "addPlayer", "id": 1}`, etag, "")
+	if resp.Code != http.StatusOK || resp.Header().Get("Preference-Applied") != "" {
+		t.Fatalf("PATCH returned %d with Preference-Applied %q", resp.Code, resp.Header().Get("Preference-Applied"))
+	}
+
+	resp = patchAction(handler, gameId, `{"action": "advancePhase"}`, etag, "")
+	if resp.Code != http.StatusPreconditionFailed {
+		t.Fatalf("PATCH of an old version returned %d; expected 412", resp.Code)
+	}
+
+	resp = patchAction(handler, gameId, `{"action": "advancePhase"}`, etag, "rebase")
+	if resp.Code != http.StatusOK || resp.Header().Get("Preference-Applied") != "rebase" {
+		t.Fatalf("rebased PATCH returned %d with Preference-Applied %q", resp.Code, resp.Header().Get("Preference-Applied"))
+	}
+
+	resp = patchAction(handler, gameId, `{"action": "addPlayer", "id": 1}`, etag, "rebase")
+	var problem powergrim.Problem
+	json.NewDecoder(resp.Body).Decode(&problem)
+	if resp.Code != http.StatusConflict || problem.Code != "unique-id" {
+		t.Fatalf("rebased PATCH of a conflicting action returned %d %q; expected 409 \"unique-id\"", resp.Code, problem.Code)
+	}
+
+	resp = patchAction(handler, gameId, `{"action": "addPlayer", "id": 1}`, "", "rebase")
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("PATCH of a failing action on the latest version returned %d; expected 400", resp.Code)
+	}
+
+	game := fetchGame(t, handler, gameId)
+	if game.Phase != 1 || len(game.Players) != 1 {
+		t.Fatalf("game is in phase %d with %d players; expected phase 1 with 1 player", game.Phase, len(game.Players))
+	}
+}
+
+func TestNewGameValidatesPhase(t *testing.T) {
+	handler := newTestHandler(t, testConfig(t))
+	tests := []struct {
+		body string
+		code string
+	}{
+		{`{"script": "tb", "phase": -1, "players": [], "reminders": []}`, "phase"},
+		{`{"script": "tb", "phase": 4, "players": [], "reminders": [{"character": "monk", "token": "protected", "position": 0, "expiresAt": 3}]}`, "reminder-expiry"},
+	}
+	for _, test := range tests {
+		resp := serve(handler, "POST", "/game", powergrim.GameContentType, test.body)
+		var problem powergrim.Problem
+		json.NewDecoder(resp.Body).Decode(&problem)
+		if resp.Code != http.StatusBadRequest || problem.Code != test.code {
+			t.Errorf("POST /game with %s returned %d %q; expected 400 %q", test.body, resp.Code, problem.Code, test.code)
+		}
+	}
+	createGame(t, handler, `{"script": "tb", "phase": 4, "players": [], "reminders": [{"character": "monk", "token": "protected", "position": 0, "expiresAt": 5}]}`)
+}
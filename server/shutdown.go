@@ -0,0 +1,54 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// shuttingDown is closed when the server starts shutting down, so that
+// long-running handlers can finish early instead of holding up the drain.
+var shuttingDown = make(chan struct{})
+
+func serveUntilSignalled(server *http.Server, shutdownTimeout time.Duration) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	server.RegisterOnShutdown(func() { close(shuttingDown) })
+
+	slog.Info("listening", "addr", server.Addr)
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- server.ListenAndServe() }()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+	stop()
+	slog.Info("shutting down, draining in-flight requests", "timeout", shutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); errors.Is(err, context.DeadlineExceeded) {
+		slog.Warn("shutdown deadline exceeded, closing remaining connections")
+		server.Close()
+	}
+	flushGames()
+	return <-serveErr
+}
+
+func flushGames() {
+	if config.Storage == "memory" {
+		return
+	}
+	count := 0
+	games.Range(func(gameId string, game VersionedGame) {
+		saveGame(gameId, game)
+		count++
+	})
+	slog.Info("flushed games", "games", count, "storage", config.Storage)
+}
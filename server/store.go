@@ -0,0 +1,101 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/phedny/powergrim-server/grimoire"
+)
+
+type gameStore interface {
+	load() (map[string]VersionedGame, error)
+	save(gameId string, game VersionedGame) error
+}
+
+func newGameStore(config Config) (gameStore, error) {
+	switch config.Storage {
+	case "file":
+		if err := os.MkdirAll(config.DataDir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create %s directory: %w", config.DataDir, err)
+		}
+		return fileStore{dirName: config.DataDir}, nil
+	default:
+		return memoryStore{}, nil
+	}
+}
+
+type memoryStore struct{}
+
+func (memoryStore) load() (map[string]VersionedGame, error) {
+	return make(map[string]VersionedGame), nil
+}
+
+func (memoryStore) save(gameId string, game VersionedGame) error {
+	return nil
+}
+
+type fileStore struct {
+	dirName string
+}
+
+type storedGame struct {
+	LastModified time.Time             `json:"lastModified"`
+	Version      int                   `json:"version"`
+	Game         grimoire.Game         `json:"game"`
+	History      []HistoryEntry        `json:"history,omitempty"`
+	JoinCode     JoinCode              `json:"joinCode"`
+	Webhooks     []WebhookSubscription `json:"webhooks,omitempty"`
+}
+
+func (store fileStore) load() (map[string]VersionedGame, error) {
+	entries, err := os.ReadDir(store.dirName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s directory: %w", store.dirName, err)
+	}
+	games := make(map[string]VersionedGame)
+	for _, entry := range entries {
+		if filepath.Ext(entry.Name()) != ".json" || !entry.Type().IsRegular() {
+			continue
+		}
+		data, err := os.ReadFile(path.Join(store.dirName, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s/%s file: %w", store.dirName, entry.Name(), err)
+		}
+		var stored storedGame
+		if err := json.Unmarshal(data, &stored); err != nil {
+			return nil, fmt.Errorf("failed to parse %s/%s file: %w", store.dirName, entry.Name(), err)
+		}
+		games[entry.Name()[:len(entry.Name())-5]] = VersionedGame{
+			LastModified: stored.LastModified,
+			Version:      stored.Version,
+			Game:         stored.Game,
+			History:      stored.History,
+			JoinCode:     stored.JoinCode,
+			Webhooks:     stored.Webhooks,
+		}
+	}
+	return games, nil
+}
+
+func (store fileStore) save(gameId string, game VersionedGame) error {
+	data, err := json.Marshal(storedGame{
+		LastModified: game.LastModified,
+		Version:      game.Version,
+		Game:         game.Game,
+		History:      game.History,
+		JoinCode:     game.JoinCode,
+		Webhooks:     game.Webhooks,
+	})
+	if err != nil {
+		return err
+	}
+	tmpPath := path.Join(store.dirName, gameId+".json.tmp")
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", tmpPath, err)
+	}
+	return os.Rename(tmpPath, path.Join(store.dirName, gameId+".json"))
+}
@@ -0,0 +1,346 @@
+package server
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidPath = errors.New("invalid SVG path")
+
+const pathSamplesPerCurve = 64
+
+type point struct {
+	x, y float64
+}
+
+func (p point) add(q point) point {
+	return point{p.x + q.x, p.y + q.y}
+}
+
+func (p point) distance(q point) float64 {
+	return math.Hypot(q.x-p.x, q.y-p.y)
+}
+
+func lerp(p, q point, t float64) point {
+	return point{p.x + (q.x-p.x)*t, p.y + (q.y-p.y)*t}
+}
+
+type pathSegment func(t float64) point
+
+type svgPath struct {
+	segments []pathSegment
+	closed   bool
+}
+
+type pathScanner struct {
+	data string
+	pos  int
+}
+
+func (s *pathScanner) skipSeparators() {
+	for s.pos < len(s.data) && strings.IndexByte(" \t\r\n,", s.data[s.pos]) != -1 {
+		s.pos++
+	}
+}
+
+func (s *pathScanner) command() (byte, bool) {
+	s.skipSeparators()
+	if s.pos < len(s.data) && strings.IndexByte("MmLlHhVvAaCcQqZz", s.data[s.pos]) != -1 {
+		s.pos++
+		return s.data[s.pos-1], true
+	}
+	return 0, false
+}
+
+func (s *pathScanner) hasNumber() bool {
+	s.skipSeparators()
+	return s.pos < len(s.data) && strings.IndexByte("+-.0123456789", s.data[s.pos]) != -1
+}
+
+func (s *pathScanner) number() (float64, error) {
+	s.skipSeparators()
+	start := s.pos
+	if s.pos < len(s.data) && (s.data[s.pos] == '+' || s.data[s.pos] == '-') {
+		s.pos++
+	}
+	seenDot, seenDigit, seenExponent := false, false, false
+scan:
+	for ; s.pos < len(s.data); s.pos++ {
+		c := s.data[s.pos]
+		switch {
+		case c >= '0' && c <= '9':
+			seenDigit = true
+		case c == '.' && !seenDot && !seenExponent:
+			seenDot = true
+		case (c == 'e' || c == 'E') && seenDigit && !seenExponent:
+			seenExponent = true
+			if s.pos+1 < len(s.data) && (s.data[s.pos+1] == '+' || s.data[s.pos+1] == '-') {
+				s.pos++
+			}
+		default:
+			break scan
+		}
+	}
+	if !seenDigit {
+		return 0, ErrInvalidPath
+	}
+	n, err := strconv.ParseFloat(s.data[start:s.pos], 64)
+	if err != nil {
+		return 0, ErrInvalidPath
+	}
+	return n, nil
+}
+
+func (s *pathScanner) flag() (bool, error) {
+	s.skipSeparators()
+	if s.pos < len(s.data) && (s.data[s.pos] == '0' || s.data[s.pos] == '1') {
+		s.pos++
+		return s.data[s.pos-1] == '1', nil
+	}
+	return false, ErrInvalidPath
+}
+
+func (s *pathScanner) point(relativeTo point, relative bool) (point, error) {
+	x, err := s.number()
+	if err != nil {
+		return point{}, err
+	}
+	y, err := s.number()
+	if err != nil {
+		return point{}, err
+	}
+	if relative {
+		return relativeTo.add(point{x, y}), nil
+	}
+	return point{x, y}, nil
+}
+
+func parseSvgPath(data string) (svgPath, error) {
+	var path svgPath
+	s := pathScanner{data: data}
+	var current, start point
+	command, ok := s.command()
+	if !ok || (command != 'M' && command != 'm') {
+		return svgPath{}, ErrInvalidPath
+	}
+	for {
+		relative := command >= 'a'
+		switch command {
+		case 'M', 'm':
+			p, err := s.point(current, relative)
+			if err != nil {
+				return svgPath{}, err
+			}
+			current, start = p, p
+			command = 'L' + (command - 'M')
+		case 'L', 'l':
+			p, err := s.point(current, relative)
+			if err != nil {
+				return svgPath{}, err
+			}
+			path.segments = append(path.segments, lineSegment(current, p))
+			current = p
+		case 'H', 'h', 'V', 'v':
+			n, err := s.number()
+			if err != nil {
+				return svgPath{}, err
+			}
+			p := current
+			switch command {
+			case 'H':
+				p.x = n
+			case 'h':
+				p.x += n
+			case 'V':
+				p.y = n
+			case 'v':
+				p.y += n
+			}
+			path.segments = append(path.segments, lineSegment(current, p))
+			current = p
+		case 'Q', 'q':
+			c, err := s.point(current, relative)
+			if err != nil {
+				return svgPath{}, err
+			}
+			p, err := s.point(current, relative)
+			if err != nil {
+				return svgPath{}, err
+			}
+			path.segments = append(path.segments, quadraticSegment(current, c, p))
+			current = p
+		case 'C', 'c':
+			c1, err := s.point(current, relative)
+			if err != nil {
+				return svgPath{}, err
+			}
+			c2, err := s.point(current, relative)
+			if err != nil {
+				return svgPath{}, err
+			}
+			p, err := s.point(current, relative)
+			if err != nil {
+				return svgPath{}, err
+			}
+			path.segments = append(path.segments, cubicSegment(current, c1, c2, p))
+			current = p
+		case 'A', 'a':
+			rx, err := s.number()
+			if err != nil {
+				return svgPath{}, err
+			}
+			ry, err := s.number()
+			if err != nil {
+				return svgPath{}, err
+			}
+			rotation, err := s.number()
+			if err != nil {
+				return svgPath{}, err
+			}
+			largeArc, err := s.flag()
+			if err != nil {
+				return svgPath{}, err
+			}
+			sweep, err := s.flag()
+			if err != nil {
+				return svgPath{}, err
+			}
+			p, err := s.point(current, relative)
+			if err != nil {
+				return svgPath{}, err
+			}
+			path.segments = append(path.segments, arcSegment(current, rx, ry, rotation, largeArc, sweep, p))
+			current = p
+		case 'Z', 'z':
+			if current != start {
+				path.segments = append(path.segments, lineSegment(current, start))
+			}
+			current = start
+			path.closed = true
+		}
+		if command != 'Z' && command != 'z' && s.hasNumber() {
+			continue
+		}
+		s.skipSeparators()
+		if s.pos == len(s.data) {
+			break
+		}
+		if command, ok = s.command(); !ok {
+			return svgPath{}, ErrInvalidPath
+		}
+	}
+	if len(path.segments) == 0 {
+		return svgPath{}, ErrInvalidPath
+	}
+	if current == start {
+		path.closed = true
+	}
+	return path, nil
+}
+
+func lineSegment(p0, p1 point) pathSegment {
+	return func(t float64) point {
+		return lerp(p0, p1, t)
+	}
+}
+
+func quadraticSegment(p0, c, p1 point) pathSegment {
+	return func(t float64) point {
+		return lerp(lerp(p0, c, t), lerp(c, p1, t), t)
+	}
+}
+
+func cubicSegment(p0, c1, c2, p1 point) pathSegment {
+	return func(t float64) point {
+		a, b, c := lerp(p0, c1, t), lerp(c1, c2, t), lerp(c2, p1, t)
+		return lerp(lerp(a, b, t), lerp(b, c, t), t)
+	}
+}
+
+// arcSegment converts the endpoint parameterization of an elliptical arc to
+// the center parameterization, following the SVG implementation notes.
+func arcSegment(p0 point, rx, ry, rotation float64, largeArc, sweep bool, p1 point) pathSegment {
+	rx, ry = math.Abs(rx), math.Abs(ry)
+	if rx == 0 || ry == 0 || p0 == p1 {
+		return lineSegment(p0, p1)
+	}
+	phi := rotation * math.Pi / 180
+	cosPhi, sinPhi := math.Cos(phi), math.Sin(phi)
+	dx, dy := (p0.x-p1.x)/2, (p0.y-p1.y)/2
+	x1 := cosPhi*dx + sinPhi*dy
+	y1 := -sinPhi*dx + cosPhi*dy
+	if lambda := x1*x1/(rx*rx) + y1*y1/(ry*ry); lambda > 1 {
+		rx, ry = rx*math.Sqrt(lambda), ry*math.Sqrt(lambda)
+	}
+	num := rx*rx*ry*ry - rx*rx*y1*y1 - ry*ry*x1*x1
+	den := rx*rx*y1*y1 + ry*ry*x1*x1
+	coef := math.Sqrt(math.Max(0, num/den))
+	if largeArc == sweep {
+		coef = -coef
+	}
+	cx1, cy1 := coef*rx*y1/ry, -coef*ry*x1/rx
+	cx := cosPhi*cx1 - sinPhi*cy1 + (p0.x+p1.x)/2
+	cy := sinPhi*cx1 + cosPhi*cy1 + (p0.y+p1.y)/2
+	theta1 := math.Atan2((y1-cy1)/ry, (x1-cx1)/rx)
+	theta2 := math.Atan2((-y1-cy1)/ry, (-x1-cx1)/rx)
+	delta := theta2 - theta1
+	if sweep && delta < 0 {
+		delta += 2 * math.Pi
+	} else if !sweep && delta > 0 {
+		delta -= 2 * math.Pi
+	}
+	return func(t float64) point {
+		theta := theta1 + delta*t
+		x, y := rx*math.Cos(theta), ry*math.Sin(theta)
+		return point{cosPhi*x - sinPhi*y + cx, sinPhi*x + cosPhi*y + cy}
+	}
+}
+
+func (path svgPath) flatten() []point {
+	points := []point{path.segments[0](0)}
+	for _, segment := range path.segments {
+		for i := 1; i <= pathSamplesPerCurve; i++ {
+			points = append(points, segment(float64(i)/pathSamplesPerCurve))
+		}
+	}
+	return points
+}
+
+func SeatPositions(seatingPath string, players int) ([][2]int, error) {
+	path, err := parseSvgPath(seatingPath)
+	if err != nil {
+		return nil, err
+	}
+	if players <= 0 {
+		return [][2]int{}, nil
+	}
+	points := path.flatten()
+	lengths := make([]float64, len(points))
+	for i := 1; i < len(points); i++ {
+		lengths[i] = lengths[i-1] + points[i-1].distance(points[i])
+	}
+	total := lengths[len(lengths)-1]
+	spacing := 0.0
+	switch {
+	case path.closed:
+		spacing = total / float64(players)
+	case players > 1:
+		spacing = total / float64(players-1)
+	}
+	seats := make([][2]int, players)
+	idx := 1
+	for seat := range seats {
+		distance := spacing * float64(seat)
+		for idx < len(points)-1 && lengths[idx] < distance {
+			idx++
+		}
+		p := points[idx-1]
+		if segmentLength := lengths[idx] - lengths[idx-1]; segmentLength > 0 {
+			p = lerp(points[idx-1], points[idx], math.Min(1, (distance-lengths[idx-1])/segmentLength))
+		}
+		seats[seat] = [2]int{int(math.Round(p.x)), int(math.Round(p.y))}
+	}
+	return seats, nil
+}
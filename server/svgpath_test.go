@@ -0,0 +1,59 @@
+package server_test
+
+import (
+	"reflect"
+	"testing"
+
+	powergrim "github.com/phedny/powergrim-server/server"
+)
+
+func TestSeatPositionsCircle(t *testing.T) {
+	got, err := powergrim.SeatPositions("M500,100 A400,400 0 0 1 500,900 A400,400 0 0 1 500,100", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][2]int{{500, 100}, {900, 500}, {500, 900}, {100, 500}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("SeatPositions() returned %v; expected %v", got, expected)
+	}
+}
+
+func TestSeatPositionsOpenPath(t *testing.T) {
+	got, err := powergrim.SeatPositions("M100 100 h400 l0 400", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][2]int{{100, 100}, {300, 100}, {500, 100}, {500, 300}, {500, 500}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("SeatPositions() returned %v; expected %v", got, expected)
+	}
+}
+
+func TestSeatPositionsClosedPath(t *testing.T) {
+	got, err := powergrim.SeatPositions("M0,0 L400,0 400,400 0,400 Z", 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][2]int{{0, 0}, {200, 0}, {400, 0}, {400, 200}, {400, 400}, {200, 400}, {0, 400}, {0, 200}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("SeatPositions() returned %v; expected %v", got, expected)
+	}
+}
+
+func TestSeatPositionsCurves(t *testing.T) {
+	got, err := powergrim.SeatPositions("M0,0 Q100,0 100,100 C100,150 150,200 200,200", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][2]int{{0, 0}, {200, 200}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("SeatPositions() returned %v; expected %v", got, expected)
+	}
+}
+
+func TestSeatPositionsInvalidPath(t *testing.T) {
+	got, err := powergrim.SeatPositions("L100,100", 2)
+	if err != powergrim.ErrInvalidPath {
+		t.Fatalf("SeatPositions() returned (%v, %s); expected error %s", got, err, powergrim.ErrInvalidPath)
+	}
+}
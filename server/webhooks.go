@@ -0,0 +1,588 @@
+package server
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/phedny/powergrim-server/grimoire"
+)
+
+var (
+	ErrWebhookUrl      = errors.New("url must be an absolute http or https URL")
+	ErrWebhookSecret   = errors.New("secret must be absent or have length between 16 and 256")
+	ErrTooManyWebhooks = errors.New("too many webhooks for game")
+	ErrWebhookAddress  = errors.New("webhook address is in a denied network")
+	ErrNoWebhook       = errors.New("webhook does not exist")
+)
+
+const (
+	WebhookContentType = "application/prs.powergrim.webhook+json; charset=utf-8"
+	maxWebhooksPerGame = 10
+	webhookQueueSize   = 100
+	webhookDeliveryLog = 50
+	webhookTimeout     = 10 * time.Second
+)
+
+// WebhookEvent is the body of a webhook delivery.
+type WebhookEvent struct {
+	Event            string              `json:"event"`
+	GameId           string              `json:"gameId"`
+	Version          int                 `json:"version"`
+	Actions          []HistoryEntry      `json:"actions"`
+	ExpiredReminders []grimoire.Reminder `json:"expiredReminders,omitempty"`
+	Game             grimoire.Game       `json:"game"`
+}
+
+type WebhookDelivery struct {
+	Id             string    `json:"id"`
+	Event          string    `json:"event"`
+	Version        int       `json:"version"`
+	Time           time.Time `json:"time"`
+	Attempts       int       `json:"attempts"`
+	Delivered      bool      `json:"delivered"`
+	ResponseStatus int       `json:"responseStatus,omitempty"`
+	Error          string    `json:"error,omitempty"`
+}
+
+// Webhook describes a subscription. The secret is only included in the
+// response to the request that created it.
+type Webhook struct {
+	Id         string            `json:"id"`
+	Url        string            `json:"url"`
+	Secret     string            `json:"secret,omitempty"`
+	Disabled   bool              `json:"disabled"`
+	CreatedAt  time.Time         `json:"createdAt"`
+	Deliveries []WebhookDelivery `json:"deliveries"`
+}
+
+// WebhookSubscription is the part of a webhook that is stored with its game.
+type WebhookSubscription struct {
+	Id        string    `json:"id"`
+	Url       string    `json:"url"`
+	Secret    string    `json:"secret"`
+	Disabled  bool      `json:"disabled,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type webhookPayload struct {
+	event   WebhookEvent
+	payload []byte
+}
+
+type webhook struct {
+	id        string
+	url       string
+	secret    []byte
+	createdAt time.Time
+	queue     chan webhookPayload
+	done      chan struct{}
+
+	mut        sync.Mutex
+	disabled   bool
+	failures   int
+	deliveries []WebhookDelivery
+}
+
+func (wh *webhook) describe() Webhook {
+	wh.mut.Lock()
+	defer wh.mut.Unlock()
+	return Webhook{
+		Id:         wh.id,
+		Url:        wh.url,
+		Disabled:   wh.disabled,
+		CreatedAt:  wh.createdAt,
+		Deliveries: append([]WebhookDelivery{}, wh.deliveries...),
+	}
+}
+
+func (wh *webhook) subscription() WebhookSubscription {
+	wh.mut.Lock()
+	defer wh.mut.Unlock()
+	return WebhookSubscription{
+		Id:        wh.id,
+		Url:       wh.url,
+		Secret:    string(wh.secret),
+		Disabled:  wh.disabled,
+		CreatedAt: wh.createdAt,
+	}
+}
+
+func (wh *webhook) record(delivery WebhookDelivery) {
+	wh.mut.Lock()
+	defer wh.mut.Unlock()
+	wh.deliveries = append(wh.deliveries, delivery)
+	if len(wh.deliveries) > webhookDeliveryLog {
+		wh.deliveries = slices.Clone(wh.deliveries[len(wh.deliveries)-webhookDeliveryLog:])
+	}
+}
+
+// WebhookDispatcher delivers game events to the webhooks subscribed to them.
+// Every webhook has its own queue, so that events are delivered in order and
+// a slow receiver does not hold up the others.
+type WebhookDispatcher struct {
+	client       *http.Client
+	attempts     int
+	backoff      time.Duration
+	disableAfter int
+
+	// onDisable is called when a webhook is disabled after failed deliveries.
+	onDisable func(gameId, webhookId string)
+
+	mut      sync.Mutex
+	webhooks map[string][]*webhook
+}
+
+func NewWebhookDispatcher(client *http.Client, attempts int, backoff time.Duration, disableAfter int) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		client:       client,
+		attempts:     attempts,
+		backoff:      backoff,
+		disableAfter: disableAfter,
+		webhooks:     make(map[string][]*webhook),
+	}
+}
+
+var webhooks *WebhookDispatcher
+
+// NewWebhookClient returns a client for delivering events that does not
+// follow redirects and refuses to connect to addresses in the denied
+// networks. Addresses are checked when dialing, after the host name has been
+// resolved, so that a host name cannot point a webhook at an internal service.
+func NewWebhookClient(timeout time.Duration, denied []netip.Prefix) *http.Client {
+	dialer := &net.Dialer{
+		Control: func(network, address string, conn syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			addr := addrPort.Addr().WithZone("").Unmap()
+			for _, network := range denied {
+				if network.Contains(addr) {
+					return fmt.Errorf("%w: %s", ErrWebhookAddress, addr)
+				}
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func parseNetworks(networks []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, len(networks))
+	for i, network := range networks {
+		prefix, err := netip.ParsePrefix(network)
+		if err != nil {
+			return nil, err
+		}
+		prefixes[i] = prefix.Masked()
+	}
+	return prefixes, nil
+}
+
+func (d *WebhookDispatcher) Subscribe(gameId, webhookUrl, secret string) (Webhook, error) {
+	parsed, err := url.Parse(webhookUrl)
+	if err != nil || !parsed.IsAbs() || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return Webhook{}, ErrWebhookUrl
+	}
+	if secret == "" {
+		key := make([]byte, 32)
+		rand.Read(key)
+		secret = hex.EncodeToString(key)
+	} else if len(secret) < 16 || len(secret) > 256 {
+		return Webhook{}, ErrWebhookSecret
+	}
+	wh := &webhook{
+		id:        uuid.NewString(),
+		url:       webhookUrl,
+		secret:    []byte(secret),
+		createdAt: time.Now().Truncate(time.Second),
+		queue:     make(chan webhookPayload, webhookQueueSize),
+		done:      make(chan struct{}),
+	}
+	d.mut.Lock()
+	if len(d.webhooks[gameId]) >= maxWebhooksPerGame {
+		d.mut.Unlock()
+		return Webhook{}, ErrTooManyWebhooks
+	}
+	d.webhooks[gameId] = append(slices.Clip(d.webhooks[gameId]), wh)
+	d.mut.Unlock()
+	go d.run(gameId, wh)
+	description := wh.describe()
+	description.Secret = secret
+	return description, nil
+}
+
+// Restore subscribes the webhooks of a game that were stored before.
+func (d *WebhookDispatcher) Restore(gameId string, subscriptions []WebhookSubscription) {
+	for _, subscription := range subscriptions {
+		wh := &webhook{
+			id:        subscription.Id,
+			url:       subscription.Url,
+			secret:    []byte(subscription.Secret),
+			createdAt: subscription.CreatedAt,
+			disabled:  subscription.Disabled,
+			queue:     make(chan webhookPayload, webhookQueueSize),
+			done:      make(chan struct{}),
+		}
+		d.mut.Lock()
+		d.webhooks[gameId] = append(slices.Clip(d.webhooks[gameId]), wh)
+		d.mut.Unlock()
+		if !wh.disabled {
+			go d.run(gameId, wh)
+		}
+	}
+}
+
+// Subscription returns the webhook as it is stored with its game.
+func (d *WebhookDispatcher) Subscription(gameId, webhookId string) (WebhookSubscription, bool) {
+	d.mut.Lock()
+	wh, _ := d.find(gameId, webhookId)
+	d.mut.Unlock()
+	if wh == nil {
+		return WebhookSubscription{}, false
+	}
+	return wh.subscription(), true
+}
+
+// Enable resumes deliveries to a webhook that was disabled after failed
+// deliveries. Events that occurred while it was disabled are not delivered.
+func (d *WebhookDispatcher) Enable(gameId, webhookId string) (Webhook, bool) {
+	d.mut.Lock()
+	wh, _ := d.find(gameId, webhookId)
+	d.mut.Unlock()
+	if wh == nil {
+		return Webhook{}, false
+	}
+	wh.mut.Lock()
+	disabled := wh.disabled
+	wh.disabled = false
+	wh.failures = 0
+	wh.mut.Unlock()
+	if disabled {
+		go d.run(gameId, wh)
+	}
+	return wh.describe(), true
+}
+
+func (d *WebhookDispatcher) find(gameId, webhookId string) (*webhook, int) {
+	idx := slices.IndexFunc(d.webhooks[gameId], func(wh *webhook) bool { return wh.id == webhookId })
+	if idx == -1 {
+		return nil, -1
+	}
+	return d.webhooks[gameId][idx], idx
+}
+
+func (d *WebhookDispatcher) Unsubscribe(gameId, webhookId string) bool {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+	wh, idx := d.find(gameId, webhookId)
+	if wh == nil {
+		return false
+	}
+	d.webhooks[gameId] = slices.Delete(slices.Clone(d.webhooks[gameId]), idx, idx+1)
+	close(wh.done)
+	return true
+}
+
+func (d *WebhookDispatcher) Webhook(gameId, webhookId string) (Webhook, bool) {
+	d.mut.Lock()
+	wh, _ := d.find(gameId, webhookId)
+	d.mut.Unlock()
+	if wh == nil {
+		return Webhook{}, false
+	}
+	return wh.describe(), true
+}
+
+func (d *WebhookDispatcher) Webhooks(gameId string) []Webhook {
+	d.mut.Lock()
+	subscribed := d.webhooks[gameId]
+	d.mut.Unlock()
+	descriptions := make([]Webhook, len(subscribed))
+	for i, wh := range subscribed {
+		descriptions[i] = wh.describe()
+	}
+	return descriptions
+}
+
+// Notify queues event for delivery to the enabled webhooks of the game.
+func (d *WebhookDispatcher) Notify(event WebhookEvent) {
+	d.mut.Lock()
+	subscribed := d.webhooks[event.GameId]
+	d.mut.Unlock()
+	if len(subscribed) == 0 {
+		return
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("error encoding webhook event", "game_id", event.GameId, "error", err)
+		return
+	}
+	for _, wh := range subscribed {
+		wh.mut.Lock()
+		disabled := wh.disabled
+		wh.mut.Unlock()
+		if disabled {
+			continue
+		}
+		select {
+		case wh.queue <- webhookPayload{event, payload}:
+		default:
+			webhookDeliveries.Inc("dropped")
+			wh.record(WebhookDelivery{
+				Id:      uuid.NewString(),
+				Event:   event.Event,
+				Version: event.Version,
+				Time:    time.Now(),
+				Error:   "delivery queue is full",
+			})
+		}
+	}
+}
+
+func (d *WebhookDispatcher) run(gameId string, wh *webhook) {
+	for {
+		select {
+		case <-wh.done:
+			return
+		case <-shuttingDown:
+			return
+		case payload := <-wh.queue:
+			delivery, ok := d.deliver(wh, payload)
+			wh.record(delivery)
+			if !ok {
+				return
+			}
+			if !delivery.Delivered {
+				wh.mut.Lock()
+				wh.failures++
+				disable := wh.failures >= d.disableAfter
+				wh.disabled = disable
+				wh.mut.Unlock()
+				if disable {
+					slog.Warn("disabled webhook after failed deliveries", "game_id", gameId, "webhook_id", wh.id, "failures", d.disableAfter)
+					if d.onDisable != nil {
+						d.onDisable(gameId, wh.id)
+					}
+					return
+				}
+			} else {
+				wh.mut.Lock()
+				wh.failures = 0
+				wh.mut.Unlock()
+			}
+		}
+	}
+}
+
+// deliver posts the payload to the webhook, retrying with exponential backoff.
+// It returns false if the webhook was removed or the server is shutting down
+// while waiting to retry.
+func (d *WebhookDispatcher) deliver(wh *webhook, payload webhookPayload) (WebhookDelivery, bool) {
+	delivery := WebhookDelivery{
+		Id:      uuid.NewString(),
+		Event:   payload.event.Event,
+		Version: payload.event.Version,
+		Time:    time.Now(),
+	}
+	backoff := d.backoff
+	for {
+		delivery.Attempts++
+		delivery.ResponseStatus, delivery.Error = 0, ""
+		status, err := d.post(wh, delivery.Id, payload)
+		if err != nil {
+			delivery.Error = err.Error()
+		} else {
+			delivery.ResponseStatus = status
+			delivery.Delivered = status >= 200 && status < 300
+		}
+		if delivery.Delivered {
+			webhookDeliveries.Inc("delivered")
+			return delivery, true
+		}
+		if delivery.Attempts >= d.attempts {
+			webhookDeliveries.Inc("failed")
+			return delivery, true
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-wh.done:
+			timer.Stop()
+			return delivery, false
+		case <-shuttingDown:
+			timer.Stop()
+			return delivery, false
+		}
+		backoff *= 2
+	}
+}
+
+func (d *WebhookDispatcher) post(wh *webhook, deliveryId string, payload webhookPayload) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, wh.url, bytes.NewReader(payload.payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "powergrim-server")
+	req.Header.Set("Powergrim-Event", payload.event.Event)
+	req.Header.Set("Powergrim-Delivery", deliveryId)
+	req.Header.Set("Powergrim-Timestamp", timestamp)
+	req.Header.Set("Powergrim-Signature", "sha256="+SignWebhook(wh.secret, timestamp, payload.payload))
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// SignWebhook returns the hex encoded HMAC-SHA256 of the timestamp and body of
+// a delivery, separated by a dot. Receivers should compare it with the
+// Powergrim-Signature header and reject deliveries with an old timestamp.
+func SignWebhook(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%s.", timestamp)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func createWebhook(resp http.ResponseWriter, req *http.Request) {
+	gameId := req.PathValue("gameId")
+	if _, ok := games.Get(gameId); !ok {
+		writeProblem(resp, NewProblem(http.StatusNotFound, nil))
+		return
+	}
+	contentType := req.Header["Content-Type"]
+	if len(contentType) != 1 || contentType[0] != WebhookContentType {
+		writeProblem(resp, NewProblem(http.StatusUnsupportedMediaType, nil))
+		return
+	}
+	var subscription struct {
+		Url    string `json:"url"`
+		Secret string `json:"secret"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&subscription); err != nil {
+		decodeFailed(resp, err)
+		return
+	}
+	var wh Webhook
+	_, ok, err := games.Update(gameId, func(game VersionedGame) (VersionedGame, error) {
+		var err error
+		wh, err = webhooks.Subscribe(gameId, subscription.Url, subscription.Secret)
+		if err != nil {
+			return game, err
+		}
+		subscription, _ := webhooks.Subscription(gameId, wh.Id)
+		game.Webhooks = append(slices.Clip(game.Webhooks), subscription)
+		return game, nil
+	})
+	if !ok {
+		writeProblem(resp, NewProblem(http.StatusNotFound, nil))
+		return
+	} else if errors.Is(err, ErrTooManyWebhooks) {
+		writeProblem(resp, NewProblem(http.StatusConflict, err))
+		return
+	} else if err != nil {
+		writeProblem(resp, NewProblem(http.StatusBadRequest, err))
+		return
+	}
+	header := resp.Header()
+	header.Add("Location", fmt.Sprintf("/game/%s/webhooks/%s", gameId, wh.Id))
+	header.Add("Content-Type", WebhookContentType)
+	resp.WriteHeader(http.StatusCreated)
+	json.NewEncoder(resp).Encode(wh)
+}
+
+func listWebhooks(resp http.ResponseWriter, req *http.Request) {
+	gameId := req.PathValue("gameId")
+	if _, ok := games.Get(gameId); !ok {
+		writeProblem(resp, NewProblem(http.StatusNotFound, nil))
+		return
+	}
+	resp.Header().Add("Content-Type", JsonContentType)
+	json.NewEncoder(resp).Encode(webhooks.Webhooks(gameId))
+}
+
+func getWebhook(resp http.ResponseWriter, req *http.Request) {
+	wh, ok := webhooks.Webhook(req.PathValue("gameId"), req.PathValue("webhookId"))
+	if !ok {
+		writeProblem(resp, NewProblem(http.StatusNotFound, nil))
+		return
+	}
+	resp.Header().Add("Content-Type", WebhookContentType)
+	json.NewEncoder(resp).Encode(wh)
+}
+
+func deleteWebhook(resp http.ResponseWriter, req *http.Request) {
+	gameId, webhookId := req.PathValue("gameId"), req.PathValue("webhookId")
+	_, ok, err := games.Update(gameId, func(game VersionedGame) (VersionedGame, error) {
+		if !webhooks.Unsubscribe(gameId, webhookId) {
+			return game, ErrNoWebhook
+		}
+		game.Webhooks = slices.DeleteFunc(slices.Clone(game.Webhooks), func(subscription WebhookSubscription) bool {
+			return subscription.Id == webhookId
+		})
+		return game, nil
+	})
+	if !ok || err != nil {
+		writeProblem(resp, NewProblem(http.StatusNotFound, nil))
+		return
+	}
+	resp.WriteHeader(http.StatusNoContent)
+}
+
+func enableWebhook(resp http.ResponseWriter, req *http.Request) {
+	gameId, webhookId := req.PathValue("gameId"), req.PathValue("webhookId")
+	wh, ok := webhooks.Enable(gameId, webhookId)
+	if !ok {
+		writeProblem(resp, NewProblem(http.StatusNotFound, nil))
+		return
+	}
+	saveWebhook(gameId, webhookId)
+	resp.Header().Add("Content-Type", WebhookContentType)
+	json.NewEncoder(resp).Encode(wh)
+}
+
+// saveWebhook stores the current state of a webhook with its game. It reads
+// the state while holding the lock of the game, so that concurrent changes
+// are stored in the order they were made.
+func saveWebhook(gameId, webhookId string) {
+	games.Update(gameId, func(game VersionedGame) (VersionedGame, error) {
+		idx := slices.IndexFunc(game.Webhooks, func(subscription WebhookSubscription) bool { return subscription.Id == webhookId })
+		subscription, ok := webhooks.Subscription(gameId, webhookId)
+		if idx == -1 || !ok {
+			return game, ErrNoWebhook
+		}
+		game.Webhooks = slices.Clone(game.Webhooks)
+		game.Webhooks[idx] = subscription
+		return game, nil
+	})
+}
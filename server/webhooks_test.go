@@ -0,0 +1,304 @@
+package server_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	powergrim "github.com/phedny/powergrim-server/server"
+)
+
+func waitForDeliveries(t *testing.T, dispatcher *powergrim.WebhookDispatcher, webhookId string, n int) powergrim.Webhook {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		wh, _ := dispatcher.Webhook("game", webhookId)
+		if len(wh.Deliveries) >= n {
+			return wh
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("webhook has %d deliveries; expected %d", len(wh.Deliveries), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWebhookDeliveryRetries(t *testing.T) {
+	var mut sync.Mutex
+	requests := 0
+	var signature, timestamp string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		mut.Lock()
+		defer mut.Unlock()
+		requests++
+		if requests < 3 {
+			resp.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		signature = req.Header.Get("Powergrim-Signature")
+		timestamp = req.Header.Get("Powergrim-Timestamp")
+		body, _ = io.ReadAll(req.Body)
+	}))
+	defer server.Close()
+
+	dispatcher := powergrim.NewWebhookDispatcher(server.Client(), 3, time.Millisecond, 2)
+	wh, err := dispatcher.Subscribe("game", server.URL, "0123456789abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dispatcher.Notify(powergrim.WebhookEvent{Event: "actions.applied", GameId: "game", Version: 2})
+	delivery := waitForDeliveries(t, dispatcher, wh.Id, 1).Deliveries[0]
+	if !delivery.Delivered || delivery.Attempts != 3 || delivery.ResponseStatus != http.StatusOK || delivery.Version != 2 {
+		t.Fatalf("delivery is %#v", delivery)
+	}
+	mut.Lock()
+	defer mut.Unlock()
+	if expected := "sha256=" + powergrim.SignWebhook([]byte("0123456789abcdef"), timestamp, body); signature != expected {
+		t.Fatalf("delivery has signature %q; expected %q", signature, expected)
+	}
+}
+
+func TestWebhookDisabledAfterFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		resp.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	dispatcher := powergrim.NewWebhookDispatcher(server.Client(), 2, time.Millisecond, 2)
+	wh, err := dispatcher.Subscribe("game", server.URL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wh.Secret) != 64 {
+		t.Fatalf("Subscribe() generated secret %q", wh.Secret)
+	}
+	dispatcher.Notify(powergrim.WebhookEvent{Event: "actions.applied", GameId: "game", Version: 2})
+	dispatcher.Notify(powergrim.WebhookEvent{Event: "actions.applied", GameId: "game", Version: 3})
+	wh = waitForDeliveries(t, dispatcher, wh.Id, 2)
+	for _, delivery := range wh.Deliveries {
+		if delivery.Delivered || delivery.Attempts != 2 {
+			t.Fatalf("delivery is %#v", delivery)
+		}
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !wh.Disabled {
+		if time.Now().After(deadline) {
+			t.Fatalf("webhook was not disabled")
+		}
+		time.Sleep(time.Millisecond)
+		wh, _ = dispatcher.Webhook("game", wh.Id)
+	}
+	dispatcher.Notify(powergrim.WebhookEvent{Event: "actions.applied", GameId: "game", Version: 4})
+	if wh, _ := dispatcher.Webhook("game", wh.Id); len(wh.Deliveries) != 2 {
+		t.Fatalf("disabled webhook has %d deliveries; expected 2", len(wh.Deliveries))
+	}
+}
+
+func TestWebhookSubscribe(t *testing.T) {
+	dispatcher := powergrim.NewWebhookDispatcher(http.DefaultClient, 1, 0, 1)
+	if _, err := dispatcher.Subscribe("game", "ftp://example.com/hook", ""); err != powergrim.ErrWebhookUrl {
+		t.Fatalf("Subscribe() returned %v; expected %v", err, powergrim.ErrWebhookUrl)
+	}
+	if _, err := dispatcher.Subscribe("game", "https://example.com/hook", "short"); err != powergrim.ErrWebhookSecret {
+		t.Fatalf("Subscribe() returned %v; expected %v", err, powergrim.ErrWebhookSecret)
+	}
+	wh, err := dispatcher.Subscribe("game", "https://example.com/hook", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := dispatcher.Webhooks("game"); len(got) != 1 || got[0].Id != wh.Id || got[0].Secret != "" {
+		t.Fatalf("Webhooks() returned %#v", got)
+	}
+	if !dispatcher.Unsubscribe("game", wh.Id) || dispatcher.Unsubscribe("game", wh.Id) {
+		t.Fatalf("Unsubscribe() did not remove the webhook exactly once")
+	}
+}
+
+func TestWebhookEventsInVersionOrder(t *testing.T) {
+	const patches = 20
+	var mut sync.Mutex
+	var versions []int
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		var event powergrim.WebhookEvent
+		json.NewDecoder(req.Body).Decode(&event)
+		mut.Lock()
+		versions = append(versions, event.Version)
+		mut.Unlock()
+	}))
+	defer server.Close()
+	config := testConfig(t)
+	config.WebhookDenyNetworks = nil
+	handler := newTestHandler(t, config)
+	gameId := createGame(t, handler, `{"script": "tb", "players": [], "reminders": []}`)
+	resp := serve(handler, "POST", "/game/"+gameId+"/webhooks", powergrim.WebhookContentType, `{"url": "`+server.URL+`"}`)
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("POST webhooks returned %d %s", resp.Code, resp.Body)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < patches; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			serve(handler, "PATCH", "/game/"+gameId, powergrim.ActionContentType, `{"action": "advancePhase"}`)
+		}()
+	}
+	wg.Wait()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mut.Lock()
+		received := slices.Clone(versions)
+		mut.Unlock()
+		if len(received) == patches {
+			if !slices.IsSorted(received) {
+				t.Fatalf("webhook received versions %v; expected them in order", received)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("webhook received %d events; expected %d", len(received), patches)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWebhookClientDeniesNetworks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {}))
+	defer server.Close()
+
+	client := powergrim.NewWebhookClient(time.Second, []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")})
+	if _, err := client.Get(server.URL); !errors.Is(err, powergrim.ErrWebhookAddress) {
+		t.Fatalf("Get() of a denied address returned error %v; expected %s", err, powergrim.ErrWebhookAddress)
+	}
+	client = powergrim.NewWebhookClient(time.Second, []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")})
+	if _, err := client.Get(server.URL); err != nil {
+		t.Fatalf("Get() of an allowed address returned error %v", err)
+	}
+}
+
+func TestWebhookClientIgnoresRedirects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		http.Redirect(resp, req, "http://169.254.169.254/", http.StatusFound)
+	}))
+	defer server.Close()
+
+	resp, err := powergrim.NewWebhookClient(time.Second, nil).Post(server.URL, "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("Post() returned status %d; expected the redirect itself", resp.StatusCode)
+	}
+}
+
+func TestWebhookHandlers(t *testing.T) {
+	handler := newTestHandler(t, testConfig(t))
+	gameId := createGame(t, handler, `{"script": "tb", "players": [], "reminders": []}`)
+
+	if resp := serve(handler, "POST", "/game/unknown/webhooks", powergrim.WebhookContentType, `{"url": "https://example.com/hook"}`); resp.Code != http.StatusNotFound {
+		t.Errorf("POST webhooks of an unknown game returned %d; expected 404", resp.Code)
+	}
+	if resp := serve(handler, "POST", "/game/"+gameId+"/webhooks", powergrim.JsonContentType, `{"url": "https://example.com/hook"}`); resp.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("POST webhooks with JSON returned %d; expected 415", resp.Code)
+	}
+	if resp := serve(handler, "POST", "/game/"+gameId+"/webhooks", powergrim.WebhookContentType, `{"url": "ftp://example.com/hook"}`); resp.Code != http.StatusBadRequest {
+		t.Errorf("POST webhooks with an ftp URL returned %d; expected 400", resp.Code)
+	}
+
+	resp := serve(handler, "POST", "/game/"+gameId+"/webhooks", powergrim.WebhookContentType, `{"url": "https://example.com/hook", "secret": "0123456789abcdef"}`)
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("POST webhooks returned %d %s", resp.Code, resp.Body)
+	}
+	var created powergrim.Webhook
+	json.NewDecoder(resp.Body).Decode(&created)
+	location := "/game/" + gameId + "/webhooks/" + created.Id
+	if resp.Header().Get("Location") != location || created.Secret != "0123456789abcdef" {
+		t.Fatalf("POST webhooks returned Location %q and webhook %#v", resp.Header().Get("Location"), created)
+	}
+	resp = serve(handler, "GET", location, "", "")
+	var fetched powergrim.Webhook
+	json.NewDecoder(resp.Body).Decode(&fetched)
+	if resp.Code != http.StatusOK || fetched.Id != created.Id || fetched.Secret != "" {
+		t.Fatalf("GET webhook returned %d %#v", resp.Code, fetched)
+	}
+
+	if resp := serve(handler, "DELETE", location, "", ""); resp.Code != http.StatusNoContent {
+		t.Fatalf("DELETE webhook returned %d; expected 204", resp.Code)
+	}
+	if resp := serve(handler, "DELETE", location, "", ""); resp.Code != http.StatusNotFound {
+		t.Fatalf("second DELETE webhook returned %d; expected 404", resp.Code)
+	}
+	if resp := serve(handler, "GET", location, "", ""); resp.Code != http.StatusNotFound {
+		t.Fatalf("GET deleted webhook returned %d; expected 404", resp.Code)
+	}
+}
+
+func TestWebhooksPersisted(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		resp.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	config := testConfig(t)
+	config.Storage = "file"
+	config.DataDir = t.TempDir()
+	config.WebhookDenyNetworks = nil
+	config.WebhookAttempts = 1
+	config.WebhookDisableAfter = 1
+	handler := newTestHandler(t, config)
+	gameId := createGame(t, handler, `{"script": "tb", "players": [], "reminders": []}`)
+	resp := serve(handler, "POST", "/game/"+gameId+"/webhooks", powergrim.WebhookContentType, `{"url": "`+server.URL+`"}`)
+	var created powergrim.Webhook
+	json.NewDecoder(resp.Body).Decode(&created)
+	location := "/game/" + gameId + "/webhooks/" + created.Id
+
+	serve(handler, "PATCH", "/game/"+gameId, powergrim.ActionContentType, `{"action": "advancePhase"}`)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var stored struct {
+			Webhooks []powergrim.WebhookSubscription `json:"webhooks"`
+		}
+		data, _ := os.ReadFile(path.Join(config.DataDir, gameId+".json"))
+		json.Unmarshal(data, &stored)
+		if len(stored.Webhooks) == 1 && stored.Webhooks[0].Disabled {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("disabled webhook was not stored")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	handler = newTestHandler(t, config)
+	resp = serve(handler, "GET", location, "", "")
+	var restored powergrim.Webhook
+	json.NewDecoder(resp.Body).Decode(&restored)
+	if resp.Code != http.StatusOK || restored.Url != server.URL || !restored.Disabled {
+		t.Fatalf("GET restored webhook returned %d %#v", resp.Code, restored)
+	}
+
+	resp = serve(handler, "POST", location+"/enable", "", "")
+	var enabled powergrim.Webhook
+	json.NewDecoder(resp.Body).Decode(&enabled)
+	if resp.Code != http.StatusOK || enabled.Disabled {
+		t.Fatalf("POST enable returned %d %#v", resp.Code, enabled)
+	}
+	handler = newTestHandler(t, config)
+	resp = serve(handler, "GET", location, "", "")
+	restored = powergrim.Webhook{}
+	json.NewDecoder(resp.Body).Decode(&restored)
+	if restored.Disabled {
+		t.Fatalf("enabled webhook was restored disabled")
+	}
+	if resp := serve(handler, "POST", "/game/"+gameId+"/webhooks/unknown/enable", "", ""); resp.Code != http.StatusNotFound {
+		t.Fatalf("POST enable of an unknown webhook returned %d; expected 404", resp.Code)
+	}
+}